@@ -0,0 +1,51 @@
+// adnonce.go - HS1-SIV with nonces derived from the additional data
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// SealADNonce encrypts and authenticates plaintext as with Seal, using a
+// nonce derived from the additional data, which need not be transmitted.
+//
+// The nonce is the truncated HS1 digest of the additional data under a key
+// derived from the AEAD key, so this is only appropriate if the additional
+// data is unique per message (eg: it includes a sequence number). If it is
+// not, the usual nonce misuse resistance properties apply.
+func (ae *AEAD) SealADNonce(dst, plaintext, additionalData []byte) []byte {
+	var nonce [NonceSize]byte
+	if err := ae.adNonce(additionalData, &nonce); err != nil {
+		panic(err)
+	}
+	return ae.Seal(dst, nonce[:], plaintext, additionalData)
+}
+
+// OpenADNonce decrypts and authenticates the output of SealADNonce as with
+// Open.
+func (ae *AEAD) OpenADNonce(dst, ciphertext, additionalData []byte) ([]byte, error) {
+	var nonce [NonceSize]byte
+	if err := ae.adNonce(additionalData, &nonce); err != nil {
+		return nil, err
+	}
+	return ae.Open(dst, nonce[:], ciphertext, additionalData)
+}
+
+func (ae *AEAD) adNonce(additionalData []byte, nonce *[NonceSize]byte) error {
+	ae.rLock()
+	var nonceKey [KeySize]byte
+	err := deriveKey(ae.key, kdfPurposeADNonce, nil, &nonceKey)
+	ae.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	h := NewSchedule(nonceKey[:]).New()
+	_, _ = h.Write(additionalData)
+
+	var digest [HashSize]byte
+	h.Sum(digest[:0])
+	copy(nonce[:], digest[:])
+	return nil
+}
@@ -0,0 +1,52 @@
+// adnonce_test.go - HS1-SIV AD derived nonce tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestADNonce(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m := []byte("same plaintext, different sequence numbers")
+	ad1 := []byte("seq 1")
+	ad2 := []byte("seq 2")
+
+	var n1, n2 [NonceSize]byte
+	require.NoError(aead.adNonce(ad1, &n1), "adNonce()")
+	require.NoError(aead.adNonce(ad2, &n2), "adNonce()")
+	require.NotEqual(n1, n2, "adNonce()")
+
+	c1 := aead.SealADNonce(nil, m, ad1)
+	c2 := aead.SealADNonce(nil, m, ad2)
+	require.NotEqual(c1, c2, "SealADNonce(): differing AD")
+	require.Equal(c1, aead.SealADNonce(nil, m, ad1), "SealADNonce(): deterministic")
+	require.Equal(aead.Seal(nil, n1[:], m, ad1), c1, "SealADNonce() vs Seal()")
+
+	d, err := aead.OpenADNonce(nil, c1, ad1)
+	require.NoError(err, "OpenADNonce()")
+	require.Equal(m, d, "OpenADNonce()")
+
+	_, err = aead.OpenADNonce(nil, c1, ad2)
+	require.Equal(ErrOpen, err, "OpenADNonce(wrong AD)")
+
+	// The nonce key is derived, and distinct from the AEAD key.
+	var otherKey [KeySize]byte
+	_, _ = rand.Read(otherKey[:])
+	var n3 [NonceSize]byte
+	require.NoError(New(otherKey[:]).adNonce(ad1, &n3), "adNonce()")
+	require.NotEqual(n1, n3, "adNonce(): differing key")
+}
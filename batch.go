@@ -0,0 +1,175 @@
+// batch.go - HS1-SIV batch operations
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/subtle"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrInvalidBatch is the error returned when the slices passed to a batch
+// operation have mismatched lengths.
+var ErrInvalidBatch = errors.New("hs1siv: mismatched batch lengths")
+
+// SealBatchParallel seals each plaintexts[i] under nonces[i] and
+// additionalData[i], appending the result to dst[i] and storing the updated
+// slice back into dst[i]. additionalData may be nil, in which case no
+// additional data is used. The key schedule is derived once and shared.
+//
+// At most parallelism goroutines are used. If parallelism is less than 1,
+// runtime.GOMAXPROCS(0) is used instead.
+func (ae *AEAD) SealBatchParallel(dst, nonces, plaintexts, additionalData [][]byte, parallelism int) error {
+	n := len(plaintexts)
+	if len(dst) != n || len(nonces) != n || (additionalData != nil && len(additionalData) != n) {
+		return ErrInvalidBatch
+	}
+	for _, nonce := range nonces {
+		if len(nonce) != NonceSize {
+			return ErrInvalidNonceSize
+		}
+	}
+	for _, plaintext := range plaintexts {
+		if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+			return err
+		}
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return err
+	}
+	parallelFor(n, parallelism, func(i int) {
+		var ad []byte
+		if additionalData != nil {
+			ad = additionalData[i]
+		}
+
+		ctx := ae.newCtx(ks)
+		ret, out := sliceForAppend(dst[i], len(plaintexts[i])+ae.p.SIVLen)
+		ctx.encrypt(plaintexts[i], ad, nonces[i], out)
+		dst[i] = ret
+	})
+
+	return nil
+}
+
+// OpenBatch opens each ciphertexts[i] under nonces[i] and additionalData[i],
+// continuing past failures, and returns the plaintexts along with the
+// indexes of every entry that failed authentication (in ascending order).
+// The plaintext of a failed entry is nil. additionalData may be nil, in
+// which case no additional data is used. The key schedule is derived once
+// and shared.
+//
+// The returned error is only non-nil if the batch itself is malformed.
+func (ae *AEAD) OpenBatch(nonces, ciphertexts, additionalData [][]byte) (plaintexts [][]byte, failures []int, err error) {
+	n := len(ciphertexts)
+	if len(nonces) != n || (additionalData != nil && len(additionalData) != n) {
+		return nil, nil, ErrInvalidBatch
+	}
+	for _, nonce := range nonces {
+		if len(nonce) != NonceSize {
+			return nil, nil, ErrInvalidNonceSize
+		}
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return nil, nil, err
+	}
+	tagSize := ae.p.SIVLen
+	plaintexts = make([][]byte, n)
+	sivs := make([]byte, n*tagSize)
+	maybeSIVs := make([]byte, n*tagSize)
+	for i, c := range ciphertexts {
+		var ad []byte
+		if additionalData != nil {
+			ad = additionalData[i]
+		}
+
+		if !ae.isValidCiphertextSize(len(c)) {
+			continue
+		}
+
+		ctx := ae.newCtx(ks)
+		plaintexts[i] = make([]byte, len(c)-tagSize)
+		copy(sivs[i*tagSize:], c[len(c)-tagSize:])
+		ctx.decryptSIV(c, ad, nonces[i], plaintexts[i], maybeSIVs[i*tagSize:(i+1)*tagSize])
+	}
+
+	for i, ok := range ConstantTimeCompareBatch(sivs, maybeSIVs, tagSize) {
+		if ok && ae.isValidCiphertextSize(len(ciphertexts[i])) {
+			continue
+		}
+		if m := plaintexts[i]; m != nil {
+			for j := range m {
+				m[j] = 0
+			}
+			plaintexts[i] = nil
+		}
+		failures = append(failures, i)
+	}
+
+	return plaintexts, failures, nil
+}
+
+// ConstantTimeCompareBatch compares a and b, which are each the
+// concatenation of tags of tagLen bytes, and returns for each tag whether
+// or not they are equal. Each comparison takes time independent of the
+// contents of the tags. It panics if a and b differ in length, or are not
+// a multiple of tagLen.
+func ConstantTimeCompareBatch(a, b []byte, tagLen int) []bool {
+	if tagLen <= 0 || len(a) != len(b) || len(a)%tagLen != 0 {
+		panic("hs1siv: invalid batch comparison")
+	}
+
+	ret := make([]bool, len(a)/tagLen)
+	for i := range ret {
+		off := i * tagLen
+		ret[i] = subtle.ConstantTimeCompare(a[off:off+tagLen], b[off:off+tagLen]) == 1
+	}
+	return ret
+}
+
+// parallelFor calls fn(i) for each i in [0, n), from at most parallelism
+// goroutines, and returns when all calls have completed.
+func parallelFor(n, parallelism int, fn func(int)) {
+	if parallelism < 1 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	if parallelism > n {
+		parallelism = n
+	}
+
+	var (
+		wg   sync.WaitGroup
+		next int64
+	)
+	for w := 0; w < parallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= n {
+					return
+				}
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,163 @@
+// batch_test.go - HS1-SIV batch operation tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealBatchParallel(t *testing.T) {
+	require := require.New(t)
+
+	const batchSize = 64
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	dst := make([][]byte, batchSize)
+	nonces := make([][]byte, batchSize)
+	plaintexts := make([][]byte, batchSize)
+	ads := make([][]byte, batchSize)
+	for i := 0; i < batchSize; i++ {
+		nonces[i] = make([]byte, NonceSize)
+		_, _ = rand.Read(nonces[i])
+		plaintexts[i] = make([]byte, i*13)
+		_, _ = rand.Read(plaintexts[i])
+		ads[i] = []byte(fmt.Sprintf("entry %d", i))
+		dst[i] = []byte("prefix")
+	}
+
+	for _, parallelism := range []int{0, 1, 3, batchSize * 2} {
+		d := make([][]byte, batchSize)
+		for i := range d {
+			d[i] = append([]byte{}, dst[i]...)
+		}
+		err := aead.SealBatchParallel(d, nonces, plaintexts, ads, parallelism)
+		require.NoError(err, "SealBatchParallel(%d)", parallelism)
+
+		for i := range d {
+			expected := aead.Seal(append([]byte{}, dst[i]...), nonces[i], plaintexts[i], ads[i])
+			require.Equal(expected, d[i], "SealBatchParallel(%d): %d", parallelism, i)
+		}
+	}
+
+	// A nil additional data slice means no additional data.
+	d := make([][]byte, batchSize)
+	err := aead.SealBatchParallel(d, nonces, plaintexts, nil, 4)
+	require.NoError(err, "SealBatchParallel(nil ad)")
+	for i := range d {
+		require.Equal(aead.Seal(nil, nonces[i], plaintexts[i], nil), d[i], "SealBatchParallel(nil ad): %d", i)
+	}
+
+	err = aead.SealBatchParallel(d[1:], nonces, plaintexts, nil, 4)
+	require.Equal(ErrInvalidBatch, err, "SealBatchParallel(short dst)")
+	err = aead.SealBatchParallel(d, nonces, plaintexts, ads[1:], 4)
+	require.Equal(ErrInvalidBatch, err, "SealBatchParallel(short ad)")
+
+	badNonces := append([][]byte{}, nonces...)
+	badNonces[7] = badNonces[7][1:]
+	err = aead.SealBatchParallel(d, badNonces, plaintexts, nil, 4)
+	require.Equal(ErrInvalidNonceSize, err, "SealBatchParallel(bad nonce)")
+}
+
+func TestOpenBatch(t *testing.T) {
+	require := require.New(t)
+
+	const batchSize = 16
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	nonces := make([][]byte, batchSize)
+	plaintexts := make([][]byte, batchSize)
+	ciphertexts := make([][]byte, batchSize)
+	ads := make([][]byte, batchSize)
+	for i := 0; i < batchSize; i++ {
+		nonces[i] = make([]byte, NonceSize)
+		_, _ = rand.Read(nonces[i])
+		plaintexts[i] = make([]byte, 1+i*11)
+		_, _ = rand.Read(plaintexts[i])
+		ads[i] = []byte(fmt.Sprintf("entry %d", i))
+		ciphertexts[i] = aead.Seal(nil, nonces[i], plaintexts[i], ads[i])
+	}
+
+	d, failures, err := aead.OpenBatch(nonces, ciphertexts, ads)
+	require.NoError(err, "OpenBatch()")
+	require.Empty(failures, "OpenBatch(): failures")
+	require.Equal(plaintexts, d, "OpenBatch()")
+
+	// Corrupt a few entries, in different ways.
+	ciphertexts[2] = append([]byte{}, ciphertexts[2]...)
+	ciphertexts[2][0] ^= 0x23
+	ads[5] = []byte("wrong ad")
+	ciphertexts[9] = ciphertexts[9][:TagSize-1]
+	ciphertexts[15] = append([]byte{}, ciphertexts[15]...)
+	ciphertexts[15][len(ciphertexts[15])-1] ^= 0x23
+
+	d, failures, err = aead.OpenBatch(nonces, ciphertexts, ads)
+	require.NoError(err, "OpenBatch(corrupted)")
+	require.Equal([]int{2, 5, 9, 15}, failures, "OpenBatch(corrupted): failures")
+	for i := range d {
+		switch i {
+		case 2, 5, 9, 15:
+			require.Nil(d[i], "OpenBatch(corrupted): %d", i)
+		default:
+			require.Equal(plaintexts[i], d[i], "OpenBatch(corrupted): %d", i)
+		}
+	}
+
+	_, _, err = aead.OpenBatch(nonces[1:], ciphertexts, ads)
+	require.Equal(ErrInvalidBatch, err, "OpenBatch(short nonces)")
+}
+
+func TestParallelFor(t *testing.T) {
+	require := require.New(t)
+
+	for _, parallelism := range []int{1, 2, 5} {
+		var active, maxActive, calls int64
+		parallelFor(50, parallelism, func(i int) {
+			n := atomic.AddInt64(&active, 1)
+			for {
+				m := atomic.LoadInt64(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt64(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&calls, 1)
+			atomic.AddInt64(&active, -1)
+		})
+		require.EqualValues(50, calls, "parallelFor(%d): calls", parallelism)
+		require.LessOrEqual(maxActive, int64(parallelism), "parallelFor(%d): concurrency", parallelism)
+	}
+}
+
+func TestConstantTimeCompareBatch(t *testing.T) {
+	require := require.New(t)
+
+	a := make([]byte, 4*TagSize)
+	_, _ = rand.Read(a)
+	b := append([]byte{}, a...)
+	b[1*TagSize] ^= 0x01
+	b[4*TagSize-1] ^= 0x80
+
+	require.Equal([]bool{true, false, true, false}, ConstantTimeCompareBatch(a, b, TagSize), "ConstantTimeCompareBatch()")
+	require.Empty(ConstantTimeCompareBatch(nil, nil, TagSize), "ConstantTimeCompareBatch(empty)")
+
+	require.Panics(func() { ConstantTimeCompareBatch(a, b[1:], TagSize) }, "ConstantTimeCompareBatch(mismatched)")
+	require.Panics(func() { ConstantTimeCompareBatch(a[1:], b[1:], TagSize) }, "ConstantTimeCompareBatch(partial)")
+	require.Panics(func() { ConstantTimeCompareBatch(a, b, 0) }, "ConstantTimeCompareBatch(0)")
+}
@@ -0,0 +1,49 @@
+// buffers.go - HS1-SIV vectored output
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+)
+
+// FrameHeaderSize is the size of the length prefix of a SealBuffers frame
+// in bytes.
+const FrameHeaderSize = 4
+
+// ErrFrameTooLarge is the error returned when a sealed message is too large
+// to be described by a frame length prefix.
+var ErrFrameTooLarge = errors.New("hs1siv: frame too large")
+
+// SealBuffers seals plaintext as with Seal, and returns a frame consisting
+// of the big endian 32 bit length of the sealed message, the ciphertext,
+// and the tag as separate buffers, suitable for a single vectored write.
+//
+// All of the buffers share a single newly allocated backing array.
+func (ae *AEAD) SealBuffers(nonce, plaintext, additionalData []byte) (net.Buffers, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	sealedLen := uint64(len(plaintext)) + uint64(ae.Overhead())
+	if sealedLen > math.MaxUint32 {
+		return nil, ErrFrameTooLarge
+	}
+
+	buf := make([]byte, FrameHeaderSize, FrameHeaderSize+sealedLen)
+	binary.BigEndian.PutUint32(buf, uint32(sealedLen))
+	buf = ae.Seal(buf, nonce, plaintext, additionalData)
+
+	mEnd := FrameHeaderSize + len(plaintext)
+	return net.Buffers{
+		buf[:FrameHeaderSize:FrameHeaderSize],
+		buf[FrameHeaderSize:mEnd:mEnd],
+		buf[mEnd:],
+	}, nil
+}
@@ -0,0 +1,54 @@
+// buffers_test.go - HS1-SIV vectored output tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealBuffers(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("buffers ad")
+	for _, sz := range []int{0, 1, 64, 1000} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+
+		sealed := aead.Seal(nil, nonce[:], m, ad)
+		var hdr [FrameHeaderSize]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(sealed)))
+		expected := append(hdr[:], sealed...)
+
+		bufs, err := aead.SealBuffers(nonce[:], m, ad)
+		require.NoError(err, "SealBuffers(): %d", sz)
+		require.Len(bufs, 3, "SealBuffers(): %d", sz)
+		require.Equal(hdr[:], bufs[0], "SealBuffers(): %d: header", sz)
+		require.Len(bufs[1], sz, "SealBuffers(): %d: ciphertext", sz)
+		require.Len(bufs[2], TagSize, "SealBuffers(): %d: tag", sz)
+
+		var out bytes.Buffer
+		n, err := bufs.WriteTo(&out)
+		require.NoError(err, "WriteTo(): %d", sz)
+		require.EqualValues(len(expected), n, "WriteTo(): %d", sz)
+		require.Equal(expected, out.Bytes(), "SealBuffers(): %d", sz)
+	}
+
+	_, err := aead.SealBuffers(nonce[1:], nil, nil)
+	require.Equal(ErrInvalidNonceSize, err, "SealBuffers(bad nonce)")
+}
@@ -0,0 +1,85 @@
+// calibrate.go - HS1-SIV latency calibration
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"sort"
+	"time"
+)
+
+const (
+	calibrateWarmup     = 8
+	calibrateIterations = 200
+
+	estimateSize     = 16 * 1024
+	estimateDuration = 5 * time.Millisecond
+)
+
+// CalibrateSeal measures the latency of Seal for a plaintext of size bytes
+// on the current hardware, and returns the 99th percentile of the observed
+// latencies.
+//
+// This runs Seal a few hundred times under a random key, and is intended
+// to be called once at startup by latency sensitive callers to decide if
+// encryption fits within their time budget. The result is only as good as
+// the conditions it is measured under.
+func CalibrateSeal(size int) time.Duration {
+	if size < 0 {
+		panic("hs1siv: negative calibration size")
+	}
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	buf := make([]byte, size, size+TagSize)
+	for i := 0; i < calibrateWarmup; i++ {
+		_ = aead.Seal(buf[:0], nonce[:], buf, nil)
+	}
+
+	var samples [calibrateIterations]time.Duration
+	for i := range samples {
+		start := time.Now()
+		_ = aead.Seal(buf[:0], nonce[:], buf[:size], nil)
+		samples[i] = time.Since(start)
+	}
+	sort.Slice(samples[:], func(i, j int) bool { return samples[i] < samples[j] })
+
+	return samples[(len(samples)*99)/100]
+}
+
+// EstimateThroughput returns a rough estimate of the throughput of Seal on
+// the current hardware, in megabytes (10^6 bytes) of plaintext per second.
+//
+// This seals 16 KiB messages under a random key for approximately 5 ms,
+// and is cheap enough to call at startup (eg: to size a worker pool). It
+// is a single short sample, and is not a substitute for benchmarking.
+func EstimateThroughput() (mbps float64) {
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	buf := make([]byte, estimateSize, estimateSize+TagSize)
+	_ = aead.Seal(buf[:0], nonce[:], buf, nil)
+
+	var n int
+	start := time.Now()
+	var elapsed time.Duration
+	for elapsed < estimateDuration {
+		_ = aead.Seal(buf[:0], nonce[:], buf[:estimateSize], nil)
+		n++
+		elapsed = time.Since(start)
+	}
+
+	return float64(n*estimateSize) / 1e6 / elapsed.Seconds()
+}
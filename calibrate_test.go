@@ -0,0 +1,38 @@
+// calibrate_test.go - HS1-SIV latency calibration tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalibrateSeal(t *testing.T) {
+	require := require.New(t)
+
+	for _, sz := range []int{0, 64, 4096} {
+		d := CalibrateSeal(sz)
+		require.True(d > 0, "CalibrateSeal(%d): %v", sz, d)
+		t.Logf("CalibrateSeal(%d): %v", sz, d)
+	}
+
+	require.Panics(func() { CalibrateSeal(-1) }, "CalibrateSeal(-1)")
+}
+
+func TestEstimateThroughput(t *testing.T) {
+	require := require.New(t)
+
+	start := time.Now()
+	mbps := EstimateThroughput()
+	elapsed := time.Since(start)
+	require.True(mbps > 0, "EstimateThroughput(): %v", mbps)
+	require.True(elapsed < time.Second, "EstimateThroughput() took: %v", elapsed)
+	t.Logf("EstimateThroughput(): %.2f MB/s (%v)", mbps, elapsed)
+}
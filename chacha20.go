@@ -8,20 +8,77 @@
 package hs1siv
 
 import (
-	rtChacha "golang.org/x/crypto/chacha20"
+	"crypto/cipher"
+	"errors"
 )
 
 const (
-	chacha20KeySize   = rtChacha.KeySize
-	chacha20NonceSize = rtChacha.NonceSize
+	chacha20KeySize   = 32
+	chacha20NonceSize = 12
 	chacha20Rounds    = 20
 )
 
-func chacha20(key, nonce, in, out []byte, initialCounter uint32) {
-	chacha, err := rtChacha.NewUnauthenticatedCipher(key, nonce)
+// ErrStreamCipher is the error returned (or thrown via a panic by calls
+// that can not return an error) when ChaCha20 can not be instantiated, such
+// as when the key expansion is passed a key it can not handle.
+var ErrStreamCipher = errors.New("hs1siv: failed to instantiate chacha20")
+
+// The ChaCha20 implementation is selected at build time, and either way
+// chacha20 and newChaCha20 are provided. By default
+// golang.org/x/crypto/chacha20 is used, which may dispatch to assembly
+// depending on the platform, unless the pure Go implementation in
+// chacha20_ref.go is selected at runtime via SetImplementation. Building
+// with the hs1siv_purego tag instead always uses the pure Go
+// implementation, removing x/crypto and its dispatch from the library
+// entirely. The output is identical.
+//
+// ChaCha with any other number of rounds (as used by the smaller parameter
+// sets) is always done with the pure Go implementation, via chacha and
+// newChaCha.
+
+// chacha is chacha20 with a configurable number of rounds. It returns
+// ErrInvalidPlaintextSize rather than allowing the 32 bit block counter to
+// wrap, which would reuse keystream.
+func chacha(rounds int, key, nonce, in, out []byte, initialCounter uint32) error {
+	nBlocks := (uint64(len(in)) + chachaBlockSize - 1) / chachaBlockSize
+	if uint64(initialCounter)+nBlocks > 1<<32 {
+		return ErrInvalidPlaintextSize
+	}
+
+	if rounds == chacha20Rounds {
+		return chacha20(key, nonce, in, out, initialCounter)
+	}
+
+	return refChaChaXOR(rounds, key, nonce, in, out, initialCounter)
+}
+
+// newChaCha is newChaCha20 with a configurable number of rounds.
+func newChaCha(rounds int, key, nonce []byte, initialCounter uint32) (cipher.Stream, error) {
+	if rounds == chacha20Rounds {
+		return newChaCha20(key, nonce, initialCounter)
+	}
+
+	c, err := newRefChaCha(rounds, key, nonce, initialCounter)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// mustChaCha is chacha for the per-message operations, where the key and
+// nonce sizes are fixed by construction, so failure is a bug.
+func mustChaCha(rounds int, key, nonce, in, out []byte, initialCounter uint32) {
+	if err := chacha(rounds, key, nonce, in, out, initialCounter); err != nil {
+		panic(err)
+	}
+}
+
+// mustNewChaCha is newChaCha for the per-message operations, where the key
+// and nonce sizes are fixed by construction, so failure is a bug.
+func mustNewChaCha(rounds int, key, nonce []byte, initialCounter uint32) cipher.Stream {
+	stream, err := newChaCha(rounds, key, nonce, initialCounter)
 	if err != nil {
-		panic("hs1siv: failed to instantiate chacha20: " + err.Error())
+		panic(err)
 	}
-	chacha.SetCounter(initialCounter)
-	chacha.XORKeyStream(out, in)
+	return stream
 }
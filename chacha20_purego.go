@@ -0,0 +1,26 @@
+// chacha20_purego.go - ChaCha20 via the pure Go implementation
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build hs1siv_purego
+
+package hs1siv
+
+import "crypto/cipher"
+
+var availableImplementations = []string{ImplementationReference}
+
+func chacha20(key, nonce, in, out []byte, initialCounter uint32) error {
+	return refChaChaXOR(chacha20Rounds, key, nonce, in, out, initialCounter)
+}
+
+func newChaCha20(key, nonce []byte, initialCounter uint32) (cipher.Stream, error) {
+	c, err := newRefChaCha(chacha20Rounds, key, nonce, initialCounter)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,196 @@
+// chacha20_ref.go - Pure Go ChaCha implementation
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+const chachaBlockSize = 64
+
+// refChaCha is a straight forward pure Go implementation of the RFC 8439
+// ChaCha stream cipher (32 bit counter, 96 bit nonce), with a configurable
+// number of rounds. It mirrors the subset of the x/crypto/chacha20 Cipher
+// API that this package uses, and is always built so that it can be tested
+// against x/crypto, even when it is not selected.
+type refChaCha struct {
+	key     [8]uint32
+	nonce   [3]uint32
+	counter uint32
+	rounds  int
+
+	buf      [chachaBlockSize]byte
+	bufLen   int // Unused keystream bytes at the end of buf.
+	overflow bool
+}
+
+// refChaChaXOR is chacha using refChaCha, with the state on the stack.
+func refChaChaXOR(rounds int, key, nonce, in, out []byte, initialCounter uint32) error {
+	var c refChaCha
+	if err := c.init(key, nonce, rounds); err != nil {
+		return fmt.Errorf("%w: %v", ErrStreamCipher, err)
+	}
+	c.SetCounter(initialCounter)
+	c.XORKeyStream(out, in)
+	c.reset()
+	return nil
+}
+
+// newRefChaCha is newChaCha using refChaCha.
+func newRefChaCha(rounds int, key, nonce []byte, initialCounter uint32) (*refChaCha, error) {
+	c := new(refChaCha)
+	if err := c.init(key, nonce, rounds); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStreamCipher, err)
+	}
+	c.SetCounter(initialCounter)
+	return c, nil
+}
+
+func (c *refChaCha) init(key, nonce []byte, rounds int) error {
+	if len(key) != chacha20KeySize {
+		return errors.New("chacha20: wrong key size")
+	}
+	if len(nonce) != chacha20NonceSize {
+		return errors.New("chacha20: wrong nonce size")
+	}
+	if rounds <= 0 || rounds&1 != 0 {
+		return errors.New("chacha20: invalid number of rounds")
+	}
+
+	for i := range c.key {
+		c.key[i] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+	for i := range c.nonce {
+		c.nonce[i] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+	c.rounds = rounds
+	c.SetCounter(0)
+	return nil
+}
+
+// SetCounter sets the block counter, discarding any buffered keystream.
+func (c *refChaCha) SetCounter(counter uint32) {
+	c.counter = counter
+	c.bufLen = 0
+	c.overflow = false
+}
+
+// XORKeyStream XORs each byte in src with a byte from the key stream, and
+// stores the result in dst. dst and src must overlap entirely or not at all.
+func (c *refChaCha) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("chacha20: output smaller than input")
+	}
+
+	for len(src) > 0 {
+		if c.bufLen == 0 {
+			if c.overflow {
+				panic("chacha20: counter overflow")
+			}
+			c.block()
+			c.counter++
+			c.overflow = c.counter == 0
+			c.bufLen = chachaBlockSize
+		}
+
+		ks := c.buf[chachaBlockSize-c.bufLen:]
+		n := len(src)
+		if n > len(ks) {
+			n = len(ks)
+		}
+		for i, v := range src[:n] {
+			dst[i] = v ^ ks[i]
+		}
+		c.bufLen -= n
+		dst, src = dst[n:], src[n:]
+	}
+}
+
+func (c *refChaCha) reset() {
+	c.key = [8]uint32{}
+	for i := range c.buf {
+		c.buf[i] = 0
+	}
+}
+
+func quarterRound(a, b, c, d uint32) (uint32, uint32, uint32, uint32) {
+	a += b
+	d = bits.RotateLeft32(d^a, 16)
+	c += d
+	b = bits.RotateLeft32(b^c, 12)
+	a += b
+	d = bits.RotateLeft32(d^a, 8)
+	c += d
+	b = bits.RotateLeft32(b^c, 7)
+	return a, b, c, d
+}
+
+func (c *refChaCha) block() {
+	var s, x [16]uint32
+	s[0], s[1], s[2], s[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	copy(s[4:12], c.key[:])
+	s[12] = c.counter
+	copy(s[13:16], c.nonce[:])
+
+	x = s
+	for i := 0; i < c.rounds; i += 2 {
+		// Column round.
+		x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarterRound(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarterRound(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarterRound(x[3], x[7], x[11], x[15])
+
+		// Diagonal round.
+		x[0], x[5], x[10], x[15] = quarterRound(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarterRound(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarterRound(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarterRound(x[3], x[4], x[9], x[14])
+	}
+
+	for i := range x {
+		binary.LittleEndian.PutUint32(c.buf[i*4:], x[i]+s[i])
+	}
+}
+
+// hChaCha20 derives a subkey from key and the first 16 bytes of nonce, as
+// with HChaCha20 from the XChaCha20 draft (draft-irtf-cfrg-xchacha).
+func hChaCha20(key, nonce []byte, subKey *[chacha20KeySize]byte) {
+	var x [16]uint32
+	x[0], x[1], x[2], x[3] = 0x61707865, 0x3320646e, 0x79622d32, 0x6b206574
+	for i := 0; i < 8; i++ {
+		x[4+i] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+	for i := 0; i < 4; i++ {
+		x[12+i] = binary.LittleEndian.Uint32(nonce[i*4:])
+	}
+
+	for i := 0; i < chacha20Rounds; i += 2 {
+		x[0], x[4], x[8], x[12] = quarterRound(x[0], x[4], x[8], x[12])
+		x[1], x[5], x[9], x[13] = quarterRound(x[1], x[5], x[9], x[13])
+		x[2], x[6], x[10], x[14] = quarterRound(x[2], x[6], x[10], x[14])
+		x[3], x[7], x[11], x[15] = quarterRound(x[3], x[7], x[11], x[15])
+
+		x[0], x[5], x[10], x[15] = quarterRound(x[0], x[5], x[10], x[15])
+		x[1], x[6], x[11], x[12] = quarterRound(x[1], x[6], x[11], x[12])
+		x[2], x[7], x[8], x[13] = quarterRound(x[2], x[7], x[8], x[13])
+		x[3], x[4], x[9], x[14] = quarterRound(x[3], x[4], x[9], x[14])
+	}
+
+	// Unlike the block function, the input is not added back, and the
+	// output is the first and last rows of the state.
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint32(subKey[i*4:], x[i])
+		binary.LittleEndian.PutUint32(subKey[16+i*4:], x[12+i])
+	}
+	for i := range x {
+		x[i] = 0
+	}
+}
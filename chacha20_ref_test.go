@@ -0,0 +1,105 @@
+// chacha20_ref_test.go - Pure Go ChaCha implementation tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	rtChacha "golang.org/x/crypto/chacha20"
+)
+
+func TestRefChaCha(t *testing.T) {
+	require := require.New(t)
+
+	// RFC 8439 2.4.2.
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	nonce, _ := hex.DecodeString("000000000000004a00000000")
+	m := []byte("Ladies and Gentlemen of the class of '99: If I could offer you only one tip for the future, sunscreen would be it.")
+	expected, _ := hex.DecodeString("6e2e359a2568f98041ba0728dd0d6981e97e7aec1d4360c20a27afccfd9fae0bf91b65c5524733ab8f593dabcd62b3571639d624e65152ab8f530c359f0861d807ca0dbf500d6a6156a38e088a22b65e52bc514d16ccf806818ce91ab77937365af90bbf74a35be6b40b8eedf2785e42874d")
+
+	var c refChaCha
+	require.NoError(c.init(key, nonce, chacha20Rounds), "init()")
+	c.SetCounter(1)
+	out := make([]byte, len(m))
+	c.XORKeyStream(out, m)
+	require.Equal(expected, out, "XORKeyStream(): RFC 8439")
+
+	// Against x/crypto, with uneven writes.
+	for _, sz := range []int{0, 1, 63, 64, 65, 1000} {
+		_, _ = rand.Read(key)
+		_, _ = rand.Read(nonce)
+		m = make([]byte, sz)
+		_, _ = rand.Read(m)
+
+		for _, counter := range []uint32{0, 1, 0xfffffff0} {
+			x, err := rtChacha.NewUnauthenticatedCipher(key, nonce)
+			require.NoError(err, "NewUnauthenticatedCipher()")
+			x.SetCounter(counter)
+			expected = make([]byte, sz)
+			x.XORKeyStream(expected, m)
+
+			require.NoError(c.init(key, nonce, chacha20Rounds), "init()")
+			c.SetCounter(counter)
+			out = append([]byte{}, m...)
+			for off := 0; off < sz; {
+				n := 1 + (off*7)%67
+				if off+n > sz {
+					n = sz - off
+				}
+				c.XORKeyStream(out[off:off+n], out[off:off+n])
+				off += n
+			}
+			require.Equal(expected, out, "XORKeyStream(): %d, %x", sz, counter)
+		}
+	}
+
+	// The last block may be used, but not wrap around.
+	require.NoError(c.init(key, nonce, chacha20Rounds), "init()")
+	c.SetCounter(0xffffffff)
+	c.XORKeyStream(out[:chachaBlockSize], out[:chachaBlockSize])
+	require.Panics(func() { c.XORKeyStream(out[:1], out[:1]) }, "XORKeyStream(): overflow")
+
+	require.Panics(func() { c.XORKeyStream(out[:1], out[:2]) }, "XORKeyStream(): short dst")
+	require.Error(c.init(key[1:], nonce, chacha20Rounds), "init(): bad key")
+	require.Error(c.init(key, nonce[1:], chacha20Rounds), "init(): bad nonce")
+	require.Error(c.init(key, nonce, 7), "init(): odd rounds")
+	require.Error(c.init(key, nonce, 0), "init(): no rounds")
+}
+
+// BenchmarkChaCha20 measures the cost of a chacha20 call for the sizes used
+// by small messages. The 0 byte case is the instantiation cost alone, which
+// is paid twice per Seal (the SIV and the message are encrypted under
+// different keys, so the instances can not be shared).
+func BenchmarkChaCha20(b *testing.B) {
+	key, nonce := make([]byte, chacha20KeySize), make([]byte, chacha20NonceSize)
+	buf := make([]byte, 2*chachaBlockSize)
+
+	impls := Implementations()
+	defer func() { _ = SetImplementation(impls[0]) }()
+
+	for _, impl := range impls {
+		for _, sz := range []int{0, hs1SIVLen, chachaBlockSize, 2 * chachaBlockSize} {
+			b.Run(fmt.Sprintf("%s_%d", impl, sz), func(b *testing.B) {
+				if err := SetImplementation(impl); err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.SetBytes(int64(sz))
+				for i := 0; i < b.N; i++ {
+					if err := chacha20(key, nonce, buf[:sz], buf[:sz], 0); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
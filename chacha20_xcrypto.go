@@ -0,0 +1,52 @@
+// chacha20_xcrypto.go - ChaCha20 via golang.org/x/crypto
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build !hs1siv_purego
+
+package hs1siv
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	rtChacha "golang.org/x/crypto/chacha20"
+)
+
+var availableImplementations = []string{ImplementationXCrypto, ImplementationReference}
+
+func chacha20(key, nonce, in, out []byte, initialCounter uint32) error {
+	if isReferenceSelected() {
+		return refChaChaXOR(chacha20Rounds, key, nonce, in, out, initialCounter)
+	}
+
+	// Call NewUnauthenticatedCipher directly rather than via newChaCha20,
+	// so that it is inlined, and the Cipher does not escape to the heap.
+	chacha, err := rtChacha.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStreamCipher, err)
+	}
+	chacha.SetCounter(initialCounter)
+	chacha.XORKeyStream(out, in)
+	return nil
+}
+
+func newChaCha20(key, nonce []byte, initialCounter uint32) (cipher.Stream, error) {
+	if isReferenceSelected() {
+		c, err := newRefChaCha(chacha20Rounds, key, nonce, initialCounter)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+
+	chacha, err := rtChacha.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStreamCipher, err)
+	}
+	chacha.SetCounter(initialCounter)
+	return chacha, nil
+}
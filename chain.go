@@ -0,0 +1,124 @@
+// chain.go - HS1-SIV tag chained logs
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrChainBroken is the error returned when an entry of a tag chained log
+// fails to open.
+var ErrChainBroken = errors.New("hs1siv: chain broken")
+
+var chainGenesisAD = []byte("hs1siv chained log genesis")
+
+// ChainedLogger seals the entries of an append-only log, such that each
+// entry is bound to every entry that preceded it.
+//
+// Entry i is sealed under a nonce of LE64(i) (zero padded), with the tag of
+// entry i-1 prepended to the additional data. The first entry is chained
+// to a genesis tag derived from the key and a caller provided label, so
+// modifying, removing, inserting, or reordering any entry causes it and
+// every entry after it to fail verification.
+//
+// Truncating the log (dropping entries from the end) is not detectable from
+// the entries alone. Callers that care should record the Head somewhere
+// the attacker can not modify it.
+type ChainedLogger struct {
+	mu      sync.Mutex
+	ae      *AEAD
+	genesis [TagSize]byte
+	prev    [TagSize]byte
+	seq     uint64
+}
+
+// NewChainedLogger returns a new ChainedLogger for an empty log, identified
+// by label.
+func (ae *AEAD) NewChainedLogger(label []byte) *ChainedLogger {
+	l := &ChainedLogger{
+		ae: ae,
+	}
+	copy(l.genesis[:], ae.SearchToken(label, chainGenesisAD))
+	l.prev = l.genesis
+	return l
+}
+
+// Append seals the next entry of the log, and returns it in a newly
+// allocated slice.
+func (l *ChainedLogger) Append(plaintext, additionalData []byte) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.seq == ^uint64(0) {
+		panic(ErrNonceSourceExhausted)
+	}
+
+	var nonce [NonceSize]byte
+	chainNonce(&nonce, l.seq)
+	entry := l.ae.Seal(nil, nonce[:], plaintext, chainAD(&l.prev, additionalData))
+	copy(l.prev[:], entry[len(entry)-l.ae.Overhead():])
+	l.seq++
+	return entry
+}
+
+// Head returns the number of entries appended so far, and the tag of the
+// last entry (or the genesis tag if there are none).
+func (l *ChainedLogger) Head() (n uint64, tag [TagSize]byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.seq, l.prev
+}
+
+// Verify walks the chain from the genesis tag, opening each entry with the
+// corresponding additional data, and returns the plaintexts. additionalData
+// may be nil if no entry has additional data. On failure, the error wraps
+// ErrChainBroken and identifies the first entry that failed.
+//
+// Verify does not depend on, or change, the state of the logger.
+func (l *ChainedLogger) Verify(entries, additionalData [][]byte) ([][]byte, error) {
+	if additionalData != nil && len(additionalData) != len(entries) {
+		return nil, errors.New("hs1siv: mismatched entry and additional data count")
+	}
+
+	prev := l.genesis
+	var nonce [NonceSize]byte
+	plaintexts := make([][]byte, 0, len(entries))
+	for i, entry := range entries {
+		var ad []byte
+		if additionalData != nil {
+			ad = additionalData[i]
+		}
+
+		chainNonce(&nonce, uint64(i))
+		if len(entry) < l.ae.Overhead() {
+			return nil, fmt.Errorf("%w: entry %d", ErrChainBroken, i)
+		}
+		pt, err := l.ae.Open(nil, nonce[:], entry, chainAD(&prev, ad))
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d", ErrChainBroken, i)
+		}
+		copy(prev[:], entry[len(entry)-l.ae.Overhead():])
+		plaintexts = append(plaintexts, pt)
+	}
+
+	return plaintexts, nil
+}
+
+func chainNonce(nonce *[NonceSize]byte, seq uint64) {
+	*nonce = [NonceSize]byte{}
+	binary.LittleEndian.PutUint64(nonce[:], seq)
+}
+
+func chainAD(prev *[TagSize]byte, additionalData []byte) []byte {
+	ad := make([]byte, 0, TagSize+len(additionalData))
+	ad = append(ad, prev[:]...)
+	return append(ad, additionalData...)
+}
@@ -0,0 +1,117 @@
+// chain_test.go - HS1-SIV tag chained log tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainedLogger(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	label := []byte("audit log")
+	l := aead.NewChainedLogger(label)
+	n, genesis := l.Head()
+	require.Zero(n, "Head(): empty")
+	_, otherGenesis := aead.NewChainedLogger([]byte("other log")).Head()
+	require.NotEqual(genesis, otherGenesis, "Head(): genesis depends on label")
+
+	const nEntries = 8
+	var entries, ads, expected [][]byte
+	for i := 0; i < nEntries; i++ {
+		m := []byte(fmt.Sprintf("entry %d", i))
+		ad := []byte(fmt.Sprintf("ts=%d", i))
+		entries = append(entries, l.Append(m, ad))
+		ads = append(ads, ad)
+		expected = append(expected, m)
+	}
+	n, head := l.Head()
+	require.EqualValues(nEntries, n, "Head()")
+	require.Equal(entries[nEntries-1][len(entries[nEntries-1])-TagSize:], head[:], "Head(): tag")
+
+	pts, err := l.Verify(entries, ads)
+	require.NoError(err, "Verify()")
+	require.Equal(expected, pts, "Verify()")
+
+	// A fresh logger with the same key and label verifies the same log.
+	pts, err = aead.NewChainedLogger(label).Verify(entries, ads)
+	require.NoError(err, "Verify(fresh)")
+	require.Equal(expected, pts, "Verify(fresh)")
+
+	// But not with a different label.
+	_, err = aead.NewChainedLogger([]byte("other log")).Verify(entries, ads)
+	require.ErrorIs(err, ErrChainBroken, "Verify(other label)")
+
+	requireBrokenAt := func(entries, ads [][]byte, idx int, msg string) {
+		_, err := l.Verify(entries, ads)
+		require.ErrorIs(err, ErrChainBroken, msg)
+		require.Contains(err.Error(), fmt.Sprintf("entry %d", idx), msg)
+	}
+	clone := func(s [][]byte) [][]byte {
+		ret := make([][]byte, len(s))
+		for i := range s {
+			ret[i] = append([]byte{}, s[i]...)
+		}
+		return ret
+	}
+
+	// Modifying a middle entry.
+	bad := clone(entries)
+	bad[3][0] ^= 1
+	requireBrokenAt(bad, ads, 3, "Verify(modified entry)")
+
+	// Modifying a middle entry's additional data.
+	badAD := clone(ads)
+	badAD[4][0] ^= 1
+	requireBrokenAt(entries, badAD, 4, "Verify(modified ad)")
+
+	// Swapping two entries.
+	bad = clone(entries)
+	bad[2], bad[5] = bad[5], bad[2]
+	badAD = clone(ads)
+	badAD[2], badAD[5] = badAD[5], badAD[2]
+	requireBrokenAt(bad, badAD, 2, "Verify(swapped)")
+
+	// Removing a middle entry.
+	bad = append(clone(entries[:3]), entries[4:]...)
+	badAD = append(clone(ads[:3]), ads[4:]...)
+	requireBrokenAt(bad, badAD, 3, "Verify(removed)")
+
+	// Replacing a middle entry with a validly sealed one from another
+	// log breaks everything after it, even if the entry itself opens.
+	other := aead.NewChainedLogger(label)
+	for i := 0; i < 3; i++ {
+		_ = other.Append([]byte("forged"), ads[i])
+	}
+	bad = clone(entries)
+	bad[3] = other.Append([]byte("forged"), ads[3])
+	requireBrokenAt(bad, ads, 3, "Verify(replaced)")
+
+	// Truncated or malformed entries.
+	bad = clone(entries)
+	bad[1] = bad[1][:TagSize-1]
+	requireBrokenAt(bad, ads, 1, "Verify(short entry)")
+
+	_, err = l.Verify(entries, ads[1:])
+	require.Error(err, "Verify(mismatched ad count)")
+
+	// No additional data.
+	l = aead.NewChainedLogger(label)
+	entries = [][]byte{l.Append([]byte("a"), nil), l.Append(nil, nil)}
+	pts, err = l.Verify(entries, nil)
+	require.NoError(err, "Verify(nil ad)")
+	require.Equal([][]byte{[]byte("a"), {}}, pts, "Verify(nil ad)")
+}
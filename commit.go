@@ -0,0 +1,78 @@
+// commit.go - HS1-SIV ciphertext commitments
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "crypto/subtle"
+
+// CommitmentSize is the size of a ciphertext commitment in bytes.
+const CommitmentSize = HashSize
+
+// CommitmentKey returns the key used to compute and verify the commitments
+// appended by SealCommitted. It is derived from, but reveals nothing about,
+// the AEAD key, and may be disclosed to a third party auditor so that they
+// can check that a sealed message was produced under this key.
+func (ae *AEAD) CommitmentKey() [KeySize]byte {
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	var commitmentKey [KeySize]byte
+	if err := deriveKey(ae.key, kdfPurposeCommitment, nil, &commitmentKey); err != nil {
+		panic(err)
+	}
+	return commitmentKey
+}
+
+// SealCommitted encrypts and authenticates plaintext as with Seal, and
+// appends a commitment to the result, which is the HS1 digest of the SIV
+// under CommitmentKey.
+//
+// Note: This is for auditing, and does not make HS1-SIV key committing.
+func (ae *AEAD) SealCommitted(dst, nonce, plaintext, additionalData []byte) []byte {
+	ret := ae.Seal(dst, nonce, plaintext, additionalData)
+	commitmentKey := ae.CommitmentKey()
+
+	var commitment [CommitmentSize]byte
+	computeCommitment(commitmentKey[:], ret[len(ret)-ae.Overhead():], commitment[:])
+	return append(ret, commitment[:]...)
+}
+
+// OpenCommitted verifies the commitment of, then decrypts and authenticates
+// the output of SealCommitted as with Open.
+func (ae *AEAD) OpenCommitted(dst, nonce, sealed, additionalData []byte) ([]byte, error) {
+	commitmentKey := ae.CommitmentKey()
+	if !verifyCommitment(sealed, commitmentKey[:], ae.Overhead()) {
+		return nil, ErrOpen
+	}
+	return ae.Open(dst, nonce, sealed[:len(sealed)-CommitmentSize], additionalData)
+}
+
+// VerifyCommitment returns true iff the commitment appended to sealed by
+// SealCommitted is valid for commitmentKey.
+//
+// This does not require the AEAD key, and does not authenticate the
+// ciphertext itself.
+func VerifyCommitment(sealed, commitmentKey []byte) bool {
+	return verifyCommitment(sealed, commitmentKey, TagSize)
+}
+
+func verifyCommitment(sealed, commitmentKey []byte, tagSize int) bool {
+	if len(commitmentKey) != KeySize || len(sealed) < tagSize+CommitmentSize {
+		return false
+	}
+
+	sivOff := len(sealed) - (tagSize + CommitmentSize)
+	var commitment [CommitmentSize]byte
+	computeCommitment(commitmentKey, sealed[sivOff:sivOff+tagSize], commitment[:])
+	return subtle.ConstantTimeCompare(commitment[:], sealed[sivOff+tagSize:]) == 1
+}
+
+func computeCommitment(commitmentKey, siv, commitment []byte) {
+	h := NewSchedule(commitmentKey).New()
+	_, _ = h.Write(siv)
+	h.Sum(commitment[:0])
+}
@@ -0,0 +1,60 @@
+// commit_test.go - HS1-SIV ciphertext commitment tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitment(t *testing.T) {
+	require := require.New(t)
+
+	var key, otherKey [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(otherKey[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+	otherAEAD := New(otherKey[:])
+
+	m := []byte("the auditor gets to see that this was sealed")
+	ad := []byte("ad")
+
+	commitmentKey := aead.CommitmentKey()
+	otherCommitmentKey := otherAEAD.CommitmentKey()
+	require.NotEqual(key, commitmentKey, "CommitmentKey() == key")
+	require.NotEqual(commitmentKey, otherCommitmentKey, "CommitmentKey()")
+
+	sealed := aead.SealCommitted(nil, nonce[:], m, ad)
+	require.Len(sealed, len(m)+TagSize+CommitmentSize, "SealCommitted()")
+	require.Equal(aead.Seal(nil, nonce[:], m, ad), sealed[:len(m)+TagSize], "SealCommitted() prefix")
+
+	require.True(VerifyCommitment(sealed, commitmentKey[:]), "VerifyCommitment()")
+	require.False(VerifyCommitment(sealed, otherCommitmentKey[:]), "VerifyCommitment(other key)")
+	require.False(VerifyCommitment(sealed, key[:]), "VerifyCommitment(AEAD key)")
+	require.False(VerifyCommitment(sealed[:TagSize+CommitmentSize-1], commitmentKey[:]), "VerifyCommitment(short)")
+
+	d, err := aead.OpenCommitted(nil, nonce[:], sealed, ad)
+	require.NoError(err, "OpenCommitted()")
+	require.Equal(m, d, "OpenCommitted()")
+
+	_, err = otherAEAD.OpenCommitted(nil, nonce[:], sealed, ad)
+	require.Equal(ErrOpen, err, "OpenCommitted(other key)")
+
+	// The commitment covers the SIV, so tampering with either fails.
+	for _, off := range []int{len(m), len(sealed) - 1} {
+		bad := append([]byte{}, sealed...)
+		bad[off] ^= 0x01
+		require.False(VerifyCommitment(bad, commitmentKey[:]), "VerifyCommitment(bad %d)", off)
+		_, err = aead.OpenCommitted(nil, nonce[:], bad, ad)
+		require.Equal(ErrOpen, err, "OpenCommitted(bad %d)", off)
+	}
+}
@@ -0,0 +1,132 @@
+// compress.go - HS1-SIV with compression
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	compressionNone    byte = 0
+	compressionDeflate byte = 1
+)
+
+// ErrInvalidCompression is the error returned when an authenticated
+// plaintext can not be decompressed.
+var ErrInvalidCompression = errors.New("hs1siv: invalid compression")
+
+// CompressingAEAD is a wrapper around an AEAD that deflates the plaintext
+// before sealing it, if doing so makes it smaller.
+//
+// The output is flag || ciphertext || tag, where flag indicates if the
+// plaintext was compressed, and is authenticated as part of the additional
+// data.
+//
+// WARNING: Compressing before encrypting makes the ciphertext length depend
+// on the content of the plaintext and not just its length. If an attacker
+// can influence part of a plaintext that also contains secrets, this can be
+// used to recover the secrets (eg: CRIME/BREACH). Only use this for storage,
+// where this is not a concern.
+type CompressingAEAD struct {
+	ae    *AEAD
+	level int
+}
+
+// NewCompressingAEAD returns a new CompressingAEAD wrapping ae, using the
+// provided compress/flate compression level.
+func NewCompressingAEAD(ae *AEAD, level int) (*CompressingAEAD, error) {
+	// Validate the level up front.
+	if _, err := flate.NewWriter(io.Discard, level); err != nil {
+		return nil, err
+	}
+	return &CompressingAEAD{
+		ae:    ae,
+		level: level,
+	}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal
+// and Open.
+func (c *CompressingAEAD) NonceSize() int {
+	return NonceSize
+}
+
+// Overhead returns the maximum difference between the lengths of a plaintext
+// and its sealed output.
+func (c *CompressingAEAD) Overhead() int {
+	return 1 + c.ae.Overhead()
+}
+
+// Seal compresses, encrypts and authenticates plaintext as with the AEAD's
+// Seal, and appends the result to dst, returning the updated slice. If
+// compression does not make the plaintext smaller, it is sealed as is.
+//
+// The plaintext and dst must not overlap.
+func (c *CompressingAEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	flag, payload := compressionNone, plaintext
+
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, c.level)
+	_, _ = w.Write(plaintext)
+	_ = w.Close()
+	if buf.Len() < len(plaintext) {
+		flag, payload = compressionDeflate, buf.Bytes()
+	}
+
+	ret := append(dst, flag)
+	ret = c.ae.Seal(ret, nonce, payload, compressionAD(flag, additionalData))
+	memwipe(buf.Bytes())
+	return ret
+}
+
+// Open decrypts and authenticates the output of Seal, and decompresses the
+// plaintext if required, appending the result to dst and returning the
+// updated slice.
+//
+// The ciphertext and dst must not overlap.
+func (c *CompressingAEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < c.Overhead() {
+		return nil, ErrOpen
+	}
+	flag := ciphertext[0]
+	if flag != compressionNone && flag != compressionDeflate {
+		return nil, ErrOpen
+	}
+
+	ad := compressionAD(flag, additionalData)
+	if flag == compressionNone {
+		return c.ae.Open(dst, nonce, ciphertext[1:], ad)
+	}
+
+	payload, err := c.ae.Open(nil, nonce, ciphertext[1:], ad)
+	if err != nil {
+		return nil, err
+	}
+	defer memwipe(payload)
+
+	buf := bytes.NewBuffer(dst)
+	r := flate.NewReader(bytes.NewReader(payload))
+	if _, err = buf.ReadFrom(r); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCompression, err)
+	}
+	ret := buf.Bytes()
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
+
+func compressionAD(flag byte, additionalData []byte) []byte {
+	ad := make([]byte, 0, 1+len(additionalData))
+	ad = append(ad, flag)
+	return append(ad, additionalData...)
+}
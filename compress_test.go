@@ -0,0 +1,86 @@
+// compress_test.go - HS1-SIV with compression tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressingAEAD(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	c, err := NewCompressingAEAD(aead, flate.DefaultCompression)
+	require.NoError(err, "NewCompressingAEAD()")
+	_, err = NewCompressingAEAD(aead, 42)
+	require.Error(err, "NewCompressingAEAD(bad level)")
+
+	random := make([]byte, 1024)
+	_, _ = rand.Read(random)
+	ad := []byte("compress ad")
+	for _, v := range []struct {
+		name string
+		m    []byte
+		flag byte
+	}{
+		{"empty", nil, compressionNone},
+		{"random", random, compressionNone},
+		{"json", bytes.Repeat([]byte(`{"key":"value"},`), 256), compressionDeflate},
+	} {
+		sealed := c.Seal([]byte("dst"), nonce[:], v.m, ad)
+		sealed = sealed[3:]
+		require.Equal(v.flag, sealed[0], "Seal(): %s flag", v.name)
+		require.True(len(sealed) <= len(v.m)+c.Overhead(), "Seal(): %s overhead", v.name)
+		if v.flag == compressionDeflate {
+			require.True(len(sealed) < len(v.m), "Seal(): %s compressed", v.name)
+		}
+
+		pt, err := c.Open([]byte("dst"), nonce[:], sealed, ad)
+		require.NoError(err, "Open(): %s", v.name)
+		require.Equal(append([]byte("dst"), v.m...), pt, "Open(): %s", v.name)
+
+		pt, err = c.Open(nil, nonce[:], sealed, ad)
+		require.NoError(err, "Open(nil): %s", v.name)
+		require.NotNil(pt, "Open(nil): %s", v.name)
+
+		// The flag is authenticated.
+		bad := append([]byte{}, sealed...)
+		bad[0] ^= 1
+		_, err = c.Open(nil, nonce[:], bad, ad)
+		require.Equal(ErrOpen, err, "Open(flipped flag): %s", v.name)
+		bad[0] = 2
+		_, err = c.Open(nil, nonce[:], bad, ad)
+		require.Equal(ErrOpen, err, "Open(invalid flag): %s", v.name)
+
+		_, err = c.Open(nil, nonce[:], sealed, nil)
+		require.Equal(ErrOpen, err, "Open(bad ad): %s", v.name)
+
+		// The inner message is bound to the flag, and does not open with
+		// the bare AEAD.
+		_, err = aead.Open(nil, nonce[:], sealed[1:], ad)
+		require.Equal(ErrOpen, err, "AEAD.Open(): %s", v.name)
+	}
+
+	_, err = c.Open(nil, nonce[:], make([]byte, c.Overhead()-1), ad)
+	require.Equal(ErrOpen, err, "Open(short)")
+
+	// Authenticated but invalid deflate data is rejected.
+	sealed := append([]byte{compressionDeflate}, aead.Seal(nil, nonce[:], []byte{0xff, 0xff}, compressionAD(compressionDeflate, ad))...)
+	_, err = c.Open(nil, nonce[:], sealed, ad)
+	require.ErrorIs(err, ErrInvalidCompression, "Open(invalid deflate)")
+}
@@ -0,0 +1,181 @@
+// ctaudit_test.go - HS1-SIV secret dependent indexing audit
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// ctAuditFuncs are the functions that handle keys, key schedules, hash
+// state, or plaintext, by file.
+var ctAuditFuncs = map[string][]string{
+	"hs1.go": {
+		"polyStep",
+		"polyFinalize",
+		"asuHash",
+		"hashStep",
+		"hashFinalize",
+	},
+	"hs1siv.go": {
+		"xorCopyChaChaKey",
+		"setup",
+		"absorb",
+		"finalize",
+		"sivSetup",
+		"sivHashAD",
+		"sivGenerate",
+		"sivHashADVectored",
+		"sivGenerateVectored",
+		"sivFinalize",
+		"streamKey",
+		"encrypt",
+		"encryptDetached",
+		"encryptVectored",
+		"sealHashed",
+		"decrypt",
+		"decryptVectored",
+		"decryptSIV",
+		"decryptDetached",
+		"decryptDetachedVectored",
+		"openKeyed",
+		"decryptFused",
+	},
+	"chacha20_ref.go": {
+		"init",
+		"XORKeyStream",
+		"block",
+	},
+	"hash.go": {
+		"Write",
+		"sivSum",
+		"Reset",
+	},
+}
+
+// ctAuditPublic are the local variables that the audited functions may use
+// to index, all of which are loop counters or derived only from lengths and
+// the parameter set.
+var ctAuditPublic = map[string]bool{
+	"i":                 true,
+	"j":                 true,
+	"n":                 true,
+	"off":               true,
+	"cpLen":             true,
+	"inBytes":           true,
+	"aBytes":            true,
+	"mBytes":            true,
+	"cBytes":            true,
+	"mBytesWithPadding": true,
+	"nhMultiple":        true,
+	"sivLen":            true,
+	"stateLen":          true,
+}
+
+// ctAuditPublicFields are the struct fields that the audited functions may
+// use to index, all of which are derived only from lengths and the parameter
+// set.
+var ctAuditPublicFields = map[string]bool{
+	"nBuf":       true,
+	"bufLen":     true,
+	"hashRounds": true,
+	"HashRounds": true,
+	"SIVLen":     true,
+}
+
+// TestConstantTimeIndexing checks that every index or slice expression in
+// the functions that handle secret data only depends on constants, lengths
+// (len/cap), and known public loop counters, so that a secret dependent
+// memory access (eg: a lookup table indexed by key or plaintext material)
+// can not be introduced without this test failing.
+func TestConstantTimeIndexing(t *testing.T) {
+	require := require.New(t)
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", nil, 0)
+	require.NoError(err, "ParseDir()")
+	pkg := pkgs["hs1siv"]
+	require.NotNil(pkg, "package hs1siv")
+
+	// Package level constants are public.
+	consts := make(map[string]bool)
+	for _, f := range pkg.Files {
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.CONST {
+				for _, spec := range gd.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						consts[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	var checkIndex func(fn string, e ast.Expr)
+	checkIndex = func(fn string, e ast.Expr) {
+		if e == nil {
+			return
+		}
+		ast.Inspect(e, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.CallExpr:
+				// The length of a slice is public, even if the contents
+				// are not.
+				if id, ok := n.Fun.(*ast.Ident); ok && (id.Name == "len" || id.Name == "cap") {
+					return false
+				}
+				require.Fail("call in index expression", "%s: %s", fn, fset.Position(n.Pos()))
+			case *ast.SelectorExpr:
+				require.True(ctAuditPublicFields[n.Sel.Name], "%s: possibly secret dependent index '%s' at %s", fn, n.Sel.Name, fset.Position(n.Pos()))
+				return false
+			case *ast.Ident:
+				require.True(consts[n.Name] || ctAuditPublic[n.Name], "%s: possibly secret dependent index '%s' at %s", fn, n.Name, fset.Position(n.Pos()))
+			case *ast.IndexExpr, *ast.StarExpr:
+				require.Fail("memory dependent index expression", "%s: %s", fn, fset.Position(n.Pos()))
+			}
+			return true
+		})
+	}
+
+	for fileName, funcs := range ctAuditFuncs {
+		var f *ast.File
+		for path, pf := range pkg.Files {
+			if path == fileName {
+				f = pf
+			}
+		}
+		require.NotNil(f, "file: %s", fileName)
+
+		for _, fnName := range funcs {
+			var found bool
+			for _, decl := range f.Decls {
+				fd, ok := decl.(*ast.FuncDecl)
+				if !ok || fd.Name.Name != fnName || fd.Body == nil {
+					continue
+				}
+				found = true
+				ast.Inspect(fd.Body, func(n ast.Node) bool {
+					switch n := n.(type) {
+					case *ast.IndexExpr:
+						checkIndex(fnName, n.Index)
+					case *ast.SliceExpr:
+						checkIndex(fnName, n.Low)
+						checkIndex(fnName, n.High)
+						checkIndex(fnName, n.Max)
+					}
+					return true
+				})
+			}
+			require.True(found, "function: %s: %s", fileName, fnName)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// detached.go - HS1-SIV detached tags
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "crypto/subtle"
+
+// SealDetached encrypts and authenticates plaintext as with Seal, except
+// that the authentication tag is returned separately rather than appended
+// to the ciphertext. The ciphertext is appended to dst, and the tag is
+// newly allocated.
+//
+// The plaintext and dst must overlap exactly or not at all.
+func (ae *AEAD) SealDetached(dst, nonce, plaintext, additionalData []byte) (ciphertext, tag []byte) {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+	ciphertext, out := sliceForAppend(dst, len(plaintext))
+	tag = make([]byte, ae.p.SIVLen)
+	ctx.encryptDetached(plaintext, additionalData, nonce, out, tag)
+	return ciphertext, tag
+}
+
+// OpenDetached decrypts and authenticates ciphertext as with Open, except
+// that the authentication tag is passed separately rather than appended to
+// the ciphertext (eg: as returned by SealDetached or SplitTag).
+//
+// The ciphertext and dst must overlap exactly or not at all, and the tag
+// may alias either.
+func (ae *AEAD) OpenDetached(dst, nonce, ciphertext, tag, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if len(tag) != ae.Overhead() {
+		return nil, ErrInvalidTagSize
+	}
+	if checkPlaintextSize(uint64(len(ciphertext))) != nil {
+		return nil, ErrOpen
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return nil, err
+	}
+	ctx := ae.newCtx(ks)
+
+	var sivBuf, maybeSIVBuf [hs1SIVLen]byte
+	siv, maybeSIV := sivBuf[:len(tag)], maybeSIVBuf[:len(tag)]
+	copy(siv, tag)
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	ctx.decryptDetached(ciphertext, siv, additionalData, nonce, out, maybeSIV)
+	ok := subtle.ConstantTimeCompare(siv, maybeSIV) == 1
+	memwipe(sivBuf[:])
+	memwipe(maybeSIVBuf[:])
+	if !ok {
+		// On decryption failures, purge the invalid plaintext.
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
+
+// OpenColumns opens a message stored as separate nonce, ciphertext, and tag
+// (eg: in separate database columns). Unlike OpenDetached, each value is
+// validated up front and a distinct error is returned for each, so that a
+// wrongly sized column is distinguishable from an authentication failure.
+//
+// The returned plaintext is newly allocated.
+func (ae *AEAD) OpenColumns(nonce, ciphertext, tag, additionalData []byte) ([]byte, error) {
+	switch {
+	case len(nonce) != NonceSize:
+		return nil, ErrInvalidNonceSize
+	case uint64(len(ciphertext)) > MaxPlaintextSize:
+		return nil, ErrInvalidCiphertextSize
+	case len(tag) != ae.Overhead():
+		return nil, ErrInvalidTagSize
+	}
+	return ae.OpenDetached(nil, nonce, ciphertext, tag, additionalData)
+}
@@ -0,0 +1,123 @@
+// detached_test.go - HS1-SIV detached tag tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenDetached(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("detached ad")
+	for _, sz := range []int{0, 1, 63, 64, 65, 1000} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		sealed := aead.Seal(nil, nonce[:], m, ad)
+		c, tag, err := aead.SplitTag(sealed)
+		require.NoError(err, "SplitTag(): %d", sz)
+
+		pt, err := aead.OpenDetached(nil, nonce[:], c, tag, ad)
+		require.NoError(err, "OpenDetached(): %d", sz)
+		require.NotNil(pt, "OpenDetached(): %d", sz)
+		require.Equal(m, pt, "OpenDetached(): %d", sz)
+
+		pt, err = aead.OpenColumns(nonce[:], c, tag, ad)
+		require.NoError(err, "OpenColumns(): %d", sz)
+		require.Equal(m, pt, "OpenColumns(): %d", sz)
+
+		// In place, with the tag aliasing the output.
+		buf := append([]byte{}, sealed...)
+		pt, err = aead.OpenDetached(buf[:0], nonce[:], buf[:sz], buf[sz:], ad)
+		require.NoError(err, "OpenDetached(in place): %d", sz)
+		require.Equal(m, pt, "OpenDetached(in place): %d", sz)
+
+		badTag := append([]byte{}, tag...)
+		badTag[0] ^= 1
+		dst := make([]byte, 0, sz)
+		pt, err = aead.OpenDetached(dst, nonce[:], c, badTag, ad)
+		require.Equal(ErrOpen, err, "OpenDetached(bad tag): %d", sz)
+		require.Nil(pt, "OpenDetached(bad tag): %d", sz)
+		require.Equal(make([]byte, sz), dst[:sz], "OpenDetached(bad tag): purged %d", sz)
+
+		_, err = aead.OpenColumns(nonce[:], c, tag, nil)
+		require.Equal(ErrOpen, err, "OpenColumns(bad ad): %d", sz)
+	}
+
+	// Each column has a distinct error.
+	tag := aead.Seal(nil, nonce[:], nil, nil)
+	_, err := aead.OpenColumns(nonce[1:], nil, tag, nil)
+	require.Equal(ErrInvalidNonceSize, err, "OpenColumns(short nonce)")
+	_, err = aead.OpenColumns(append(nonce[:], 0), nil, tag, nil)
+	require.Equal(ErrInvalidNonceSize, err, "OpenColumns(long nonce)")
+	_, err = aead.OpenColumns(nonce[:], nil, tag[1:], nil)
+	require.Equal(ErrInvalidTagSize, err, "OpenColumns(short tag)")
+	_, err = aead.OpenColumns(nonce[:], nil, append(tag, 0), nil)
+	require.Equal(ErrInvalidTagSize, err, "OpenColumns(long tag)")
+	_, err = aead.OpenDetached(nil, nonce[:], nil, nil, nil)
+	require.Equal(ErrInvalidTagSize, err, "OpenDetached(no tag)")
+	require.Panics(func() { _, _ = aead.OpenDetached(nil, nil, nil, tag, nil) }, "OpenDetached(no nonce)")
+
+	// Empty everything with a detached tag, yields a non-nil empty slice.
+	pt, err := aead.OpenDetached(nil, nonce[:], nil, tag, nil)
+	require.NoError(err, "OpenDetached(empty)")
+	require.NotNil(pt, "OpenDetached(empty)")
+	require.Len(pt, 0, "OpenDetached(empty)")
+}
+
+func TestSealDetached(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	for name, aead := range map[string]*AEAD{
+		"New":    New(key[:]),
+		"NewMed": NewMed(key[:]),
+	} {
+		ad := []byte("detached ad")
+		for _, sz := range []int{0, 1, 63, 64, 65, 1000} {
+			m := make([]byte, sz)
+			_, _ = rand.Read(m)
+			sealed := aead.Seal(nil, nonce[:], m, ad)
+
+			c, tag := aead.SealDetached(nil, nonce[:], m, ad)
+			require.Len(tag, aead.Overhead(), "%s: SealDetached(): tag %d", name, sz)
+			require.Equal(sealed, append(append([]byte{}, c...), tag...), "%s: SealDetached(): %d", name, sz)
+
+			pt, err := aead.OpenDetached(nil, nonce[:], c, tag, ad)
+			require.NoError(err, "%s: OpenDetached(): %d", name, sz)
+			require.Equal(m, pt, "%s: OpenDetached(): %d", name, sz)
+
+			// Appending to dst, and in place.
+			prefix := []byte("prefix")
+			c, tag = aead.SealDetached(prefix, nonce[:], m, ad)
+			require.Equal(append(append([]byte{}, prefix...), sealed[:sz]...), c, "%s: SealDetached(dst): %d", name, sz)
+			require.Equal(sealed[sz:], tag, "%s: SealDetached(dst): tag %d", name, sz)
+
+			buf := append([]byte{}, m...)
+			c, tag = aead.SealDetached(buf[:0], nonce[:], buf, ad)
+			require.Equal(sealed[:sz], c, "%s: SealDetached(in place): %d", name, sz)
+			require.Equal(sealed[sz:], tag, "%s: SealDetached(in place): tag %d", name, sz)
+		}
+	}
+
+	aead := New(key[:])
+	require.PanicsWithValue(ErrInvalidNonceSize, func() { aead.SealDetached(nil, nonce[1:], nil, nil) }, "SealDetached(short nonce)")
+}
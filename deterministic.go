@@ -0,0 +1,28 @@
+// deterministic.go - HS1-SIV deterministic encryption
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// DeterministicSeal encrypts and authenticates plaintext as with Seal,
+// using an all zero nonce, so that the same plaintext and additional data
+// always produce the same ciphertext (eg: for deduplicating encrypted
+// blobs).
+//
+// WARNING: By design this leaks if two messages sealed under the same key
+// are identical, which for low entropy messages may be enough to recover
+// them. Nothing else is leaked, but unless that is acceptable, Seal with a
+// unique nonce should be used instead. The additional data can be used to
+// limit the scope in which equality is visible.
+func (ae *AEAD) DeterministicSeal(dst, plaintext, additionalData []byte) []byte {
+	return ae.Seal(dst, zeroNonce[:], plaintext, additionalData)
+}
+
+// DeterministicOpen decrypts and authenticates the output of
+// DeterministicSeal as with Open.
+func (ae *AEAD) DeterministicOpen(dst, ciphertext, additionalData []byte) ([]byte, error) {
+	return ae.Open(dst, zeroNonce[:], ciphertext, additionalData)
+}
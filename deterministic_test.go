@@ -0,0 +1,42 @@
+// deterministic_test.go - HS1-SIV deterministic encryption tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeterministic(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m := []byte("deduplicated blob")
+	ad := []byte("bucket 1")
+
+	c := aead.DeterministicSeal(nil, m, ad)
+	require.Len(c, len(m)+TagSize, "DeterministicSeal()")
+	require.Equal(c, aead.DeterministicSeal(nil, append([]byte{}, m...), ad), "DeterministicSeal(): deterministic")
+	require.Equal(aead.Seal(nil, zeroNonce[:], m, ad), c, "DeterministicSeal() vs Seal()")
+	require.NotEqual(c, aead.DeterministicSeal(nil, []byte("deduplicated blob!"), ad), "DeterministicSeal(): differing plaintext")
+	require.NotEqual(c, aead.DeterministicSeal(nil, m, []byte("bucket 2")), "DeterministicSeal(): differing AD")
+
+	d, err := aead.DeterministicOpen(nil, c, ad)
+	require.NoError(err, "DeterministicOpen()")
+	require.Equal(m, d, "DeterministicOpen()")
+
+	_, err = aead.DeterministicOpen(nil, c, nil)
+	require.Equal(ErrOpen, err, "DeterministicOpen(wrong AD)")
+	_, err = aead.DeterministicOpen(nil, c[:TagSize-1], ad)
+	require.Equal(ErrOpen, err, "DeterministicOpen(short)")
+}
@@ -0,0 +1,109 @@
+// fields.go - HS1-SIV sealing of named fields
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrInvalidFields is the error returned when a set of fields can not be
+// encoded, or an authenticated plaintext is not a canonical encoding.
+var ErrInvalidFields = errors.New("hs1siv: invalid fields")
+
+// fieldsAD is the additional data used by SealFields, so that the output
+// is domain separated from that of Seal.
+var fieldsAD = []byte("hs1siv fields v1")
+
+// SealFields seals a set of named fields as a single message, and returns
+// the output in a newly allocated slice.
+//
+// The fields are encoded into the plaintext in ascending order of name, each
+// as LE32(len(name)) || name || LE32(len(value)) || value, so both the names
+// and the values are encrypted and authenticated, and a field can not be
+// renamed, dropped, or swapped with another without Open failing.
+func (ae *AEAD) SealFields(nonce []byte, fields map[string][]byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	names := make([]string, 0, len(fields))
+	sz := 0
+	for name, value := range fields {
+		if uint64(len(name)) > math.MaxUint32 || uint64(len(value)) > math.MaxUint32 {
+			return nil, ErrInvalidFields
+		}
+		names = append(names, name)
+		sz += 8 + len(name) + len(value)
+	}
+	sort.Strings(names)
+
+	buf := make([]byte, 0, sz+TagSize)
+	var l [4]byte
+	for _, name := range names {
+		value := fields[name]
+		binary.LittleEndian.PutUint32(l[:], uint32(len(name)))
+		buf = append(buf, l[:]...)
+		buf = append(buf, name...)
+		binary.LittleEndian.PutUint32(l[:], uint32(len(value)))
+		buf = append(buf, l[:]...)
+		buf = append(buf, value...)
+	}
+
+	return ae.Seal(buf[:0], nonce, buf, fieldsAD), nil
+}
+
+// OpenFields opens the output of SealFields, and returns the fields. The
+// returned values alias a single newly allocated buffer.
+func (ae *AEAD) OpenFields(nonce, sealed []byte) (map[string][]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	b, err := ae.Open(nil, nonce, sealed, fieldsAD)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string][]byte)
+	var prevName string
+	for len(b) > 0 {
+		name, rest, ok := splitField(b)
+		if !ok {
+			return nil, ErrInvalidFields
+		}
+		value, rest, ok := splitField(rest)
+		if !ok {
+			return nil, ErrInvalidFields
+		}
+
+		// Reject anything that SealFields would not have produced.
+		if len(fields) > 0 && string(name) <= prevName {
+			return nil, ErrInvalidFields
+		}
+		prevName = string(name)
+		fields[prevName] = value
+		b = rest
+	}
+
+	return fields, nil
+}
+
+func splitField(b []byte) (field, rest []byte, ok bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	l := binary.LittleEndian.Uint32(b)
+	b = b[4:]
+	if uint64(len(b)) < uint64(l) {
+		return nil, nil, false
+	}
+	return b[:l:l], b[l:], true
+}
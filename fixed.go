@@ -0,0 +1,67 @@
+// fixed.go - HS1-SIV fixed size message sealing
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "errors"
+
+// ErrInvalidPlaintextSize is the error thrown via a panic when a plaintext
+// passed to a FixedSealer is not the configured size, or when a plaintext
+// is larger than MaxPlaintextSize, and the error returned by SealSeekable
+// and SealWithError when the plaintext is too large.
+var ErrInvalidPlaintextSize = errors.New("hs1siv: invalid plaintext size")
+
+// FixedSealer is a HS1-SIV sealer specialized for plaintexts of a single
+// fixed size. The key schedule is expanded once at construction, and
+// SealFixed does not allocate as long as dst has sufficient capacity.
+//
+// A FixedSealer is safe for concurrent use by multiple goroutines.
+type FixedSealer struct {
+	ks           keySchedule
+	plaintextLen int
+}
+
+// NewFixedSealer returns a new FixedSealer for plaintexts of exactly
+// plaintextLen bytes. The key sizes accepted are the same as with New.
+func NewFixedSealer(key []byte, plaintextLen int) *FixedSealer {
+	if !isValidKeySize(len(key)) {
+		panic(ErrInvalidKeySize)
+	}
+	if plaintextLen < 0 || uint64(plaintextLen) > MaxPlaintextSize {
+		panic(ErrInvalidPlaintextSize)
+	}
+
+	s := &FixedSealer{
+		plaintextLen: plaintextLen,
+	}
+	if err := s.ks.setup(key, &paramsHi); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// SealedSize returns the size of the output of SealFixed, which is the
+// capacity that dst requires past its length to avoid an allocation.
+func (s *FixedSealer) SealedSize() int {
+	return s.plaintextLen + TagSize
+}
+
+// SealFixed is identical to Seal, except that the plaintext MUST be exactly
+// the size passed to NewFixedSealer.
+func (s *FixedSealer) SealFixed(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if len(plaintext) != s.plaintextLen {
+		panic(ErrInvalidPlaintextSize)
+	}
+
+	ctx := aeadCtx{keySchedule: &s.ks}
+	ret, out := sliceForAppend(dst, s.plaintextLen+TagSize)
+	ctx.encrypt(plaintext, additionalData, nonce, out)
+	return ret
+}
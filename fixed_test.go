@@ -0,0 +1,68 @@
+// fixed_test.go - HS1-SIV fixed size message sealing tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedSealer(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("fixed ad")
+	for _, sz := range []int{0, 1, 64, 1200} {
+		s := NewFixedSealer(key[:], sz)
+		require.Equal(sz+TagSize, s.SealedSize(), "SealedSize(): %d", sz)
+
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		expected := aead.Seal(nil, nonce[:], m, ad)
+		require.Equal(expected, s.SealFixed(nil, nonce[:], m, ad), "SealFixed(): %d", sz)
+
+		dst := make([]byte, 0, s.SealedSize())
+		allocs := testing.AllocsPerRun(100, func() {
+			dst = s.SealFixed(dst[:0], nonce[:], m, ad)
+		})
+		require.Zero(allocs, "SealFixed(): %d: allocations", sz)
+		require.Equal(expected, dst, "SealFixed(preallocated): %d", sz)
+
+		require.PanicsWithValue(ErrInvalidPlaintextSize, func() { s.SealFixed(nil, nonce[:], make([]byte, sz+1), ad) }, "SealFixed(long): %d", sz)
+		require.PanicsWithValue(ErrInvalidNonceSize, func() { s.SealFixed(nil, nonce[1:], m, ad) }, "SealFixed(bad nonce): %d", sz)
+	}
+
+	require.PanicsWithValue(ErrInvalidPlaintextSize, func() { NewFixedSealer(key[:], -1) }, "NewFixedSealer(-1)")
+	require.PanicsWithValue(ErrInvalidKeySize, func() { NewFixedSealer(nil, 0) }, "NewFixedSealer(empty key)")
+}
+
+func BenchmarkFixedSealer(b *testing.B) {
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	const sz = 1200
+	s := NewFixedSealer(key[:], sz)
+	m := make([]byte, sz)
+	dst := make([]byte, 0, s.SealedSize())
+
+	b.SetBytes(sz)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst = s.SealFixed(dst[:0], nonce[:], m, nil)
+	}
+}
@@ -0,0 +1,48 @@
+// forcedsiv.go - HS1-SIV forced SIV encryption (testing only)
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build hs1siv_testing
+
+package hs1siv
+
+// SealWithForcedSIV encrypts plaintext under the provided SIV rather than
+// the one derived from the inputs, and returns ciphertext || siv, for the
+// construction of deliberately invalid ciphertexts in negative tests.
+//
+// The additional data is accepted for symmetry with Seal, but as only the
+// SIV depends on it, it has no effect on the output. If siv is the SIV that
+// Seal would derive, the output is identical to that of Seal.
+//
+// This is only available when built with the `hs1siv_testing` tag.
+func (ae *AEAD) SealWithForcedSIV(nonce, plaintext, additionalData, siv []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if len(siv) != ae.Overhead() {
+		panic(ErrInvalidTagSize)
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+
+	var chachaKey [chacha20KeySize]byte
+	ctx.streamKey(siv, chachaKey[:])
+	ret := make([]byte, len(plaintext)+len(siv))
+	mustChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, plaintext, ret, 1)
+	memwipe(chachaKey[:])
+	copy(ret[len(plaintext):], siv)
+	return ret
+}
@@ -0,0 +1,49 @@
+// forcedsiv_test.go - HS1-SIV forced SIV encryption tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build hs1siv_testing
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealWithForcedSIV(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m, ad := []byte("negative path"), []byte("ad")
+	expected := aead.Seal(nil, nonce[:], m, ad)
+
+	// The correct SIV reproduces Seal.
+	c := aead.SealWithForcedSIV(nonce[:], m, ad, expected[len(m):])
+	require.Equal(expected, c, "SealWithForcedSIV(correct SIV)")
+
+	// Any other SIV is rejected by Open.
+	var wrongSIV [TagSize]byte
+	_, _ = rand.Read(wrongSIV[:])
+	c = aead.SealWithForcedSIV(nonce[:], m, ad, wrongSIV[:])
+	require.Equal(wrongSIV[:], c[len(m):], "SealWithForcedSIV(wrong SIV): tag")
+	_, err := aead.Open(nil, nonce[:], c, ad)
+	require.Equal(ErrOpen, err, "Open(wrong SIV)")
+
+	// Which still decrypts consistently with the forced SIV.
+	d, ok := aead.OpenUnsafe(nonce[:], c, ad)
+	require.False(ok, "OpenUnsafe(wrong SIV)")
+	require.Equal(m, d, "OpenUnsafe(wrong SIV)")
+
+	require.Panics(func() { aead.SealWithForcedSIV(nonce[:], m, ad, wrongSIV[1:]) }, "SealWithForcedSIV(short SIV)")
+}
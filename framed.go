@@ -0,0 +1,164 @@
+// framed.go - HS1-SIV framed stream encryption
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// frameChunkSize is the maximum plaintext length of a frame.
+	frameChunkSize = 64 * 1024
+
+	// frameHeaderSize is the size of a frame header, which is the
+	// LE32(length of the sealed chunk), with the most significant bit set
+	// for the final frame.
+	frameHeaderSize = 4
+	frameLastFlag   = 1 << 31
+)
+
+// ErrInvalidFrame is the error returned when a frame header is malformed.
+var ErrInvalidFrame = errors.New("hs1siv: invalid frame")
+
+// NewWriter returns an io.WriteCloser that encrypts and authenticates
+// everything written to it, and writes the result to w as a sequence of
+// length prefixed frames, suitable for sending over a socket or storing in
+// a file.
+//
+// The frames are the chunks of the STREAM construction (see
+// NewEncryptStream), each preceded by a 4 byte header, so the reader does
+// not need to know the chunk size in advance. The nonce is a
+// StreamBaseNonceSize byte STREAM base nonce, and MUST be unique per
+// stream.
+//
+// Close MUST be called to write the final frame, and does not close w.
+func NewWriter(w io.Writer, key, nonce []byte) (io.WriteCloser, error) {
+	ae, err := newStreamAEAD(key, nonce, frameChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &encryptStream{
+		w:      w,
+		ae:     ae,
+		buf:    make([]byte, 0, frameChunkSize),
+		out:    make([]byte, 0, frameHeaderSize+frameChunkSize+ae.Overhead()),
+		size:   frameChunkSize,
+		framed: true,
+	}
+	copy(s.baseNonce[:], nonce)
+	return s, nil
+}
+
+// NewReader returns an io.Reader that decrypts and authenticates a stream
+// of frames produced by NewWriter with the same key and nonce, reading it
+// from r.
+//
+// Each frame is authenticated before any of its plaintext is returned. The
+// reader returns io.EOF only after the final frame has been authenticated,
+// ErrTruncatedStream if r ends before the final frame, ErrInvalidFrame if
+// a frame header is malformed, and ErrOpen if any frame fails to
+// authenticate. Trailing data past the final frame is left unread.
+func NewReader(r io.Reader, key, nonce []byte) (io.Reader, error) {
+	ae, err := newStreamAEAD(key, nonce, frameChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &frameReader{
+		r:   r,
+		ae:  ae,
+		buf: make([]byte, frameChunkSize+ae.Overhead()),
+		out: make([]byte, 0, frameChunkSize),
+	}
+	copy(s.baseNonce[:], nonce)
+	return s, nil
+}
+
+func putFrameHeader(b []byte, sealedLen int, last bool) {
+	hdr := uint32(sealedLen)
+	if last {
+		hdr |= frameLastFlag
+	}
+	binary.LittleEndian.PutUint32(b, hdr)
+}
+
+type frameReader struct {
+	r         io.Reader
+	ae        *AEAD
+	baseNonce [StreamBaseNonceSize]byte
+	counter   uint64
+
+	buf []byte
+	out []byte
+	off int
+	err error
+}
+
+func (s *frameReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for s.off == len(s.out) {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.err = s.openFrame(); s.err != nil {
+			s.ae.Wipe()
+		}
+	}
+
+	n := copy(p, s.out[s.off:])
+	s.off += n
+	return n, nil
+}
+
+func (s *frameReader) openFrame() error {
+	var hdrBuf [frameHeaderSize]byte
+	if _, err := io.ReadFull(s.r, hdrBuf[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrTruncatedStream
+		}
+		return err
+	}
+	hdr := binary.LittleEndian.Uint32(hdrBuf[:])
+	last := hdr&frameLastFlag != 0
+	sealedLen := int(hdr &^ frameLastFlag)
+	if sealedLen < s.ae.Overhead() || sealedLen > len(s.buf) {
+		return ErrInvalidFrame
+	}
+
+	sealed := s.buf[:sealedLen]
+	if _, err := io.ReadFull(s.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = ErrTruncatedStream
+		}
+		return err
+	}
+	if s.counter == maxStreamChunks {
+		return ErrStreamTooLong
+	}
+
+	var nonce [NonceSize]byte
+	streamNonce(&nonce, &s.baseNonce, s.counter, last)
+	out, err := s.ae.Open(s.out[:0], nonce[:], sealed, nil)
+	s.off = 0
+	if err != nil {
+		s.out = s.out[:0]
+		return err
+	}
+	s.out = out
+	s.counter++
+
+	if last {
+		return io.EOF
+	}
+	return nil
+}
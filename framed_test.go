@@ -0,0 +1,102 @@
+// framed_test.go - HS1-SIV framed stream encryption tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramed(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [StreamBaseNonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	encrypt := func(m []byte) []byte {
+		var b bytes.Buffer
+		w, err := NewWriter(&b, key[:], nonce[:])
+		require.NoError(err, "NewWriter()")
+		n, err := w.Write(m)
+		require.NoError(err, "Write()")
+		require.Equal(len(m), n, "Write()")
+		require.NoError(w.Close(), "Close()")
+		require.NoError(w.Close(), "Close() twice")
+		return b.Bytes()
+	}
+	decrypt := func(c []byte) ([]byte, error) {
+		r, err := NewReader(iotest.HalfReader(bytes.NewReader(c)), key[:], nonce[:])
+		require.NoError(err, "NewReader()")
+		return io.ReadAll(r)
+	}
+
+	m := make([]byte, 2*frameChunkSize+100)
+	_, _ = rand.Read(m)
+	for _, sz := range []int{0, 1, 100, frameChunkSize, frameChunkSize + 1, len(m)} {
+		c := encrypt(m[:sz])
+		nFrames := 1 + sz/frameChunkSize
+		if sz > 0 && sz%frameChunkSize == 0 {
+			nFrames--
+		}
+		require.Len(c, sz+nFrames*(frameHeaderSize+TagSize), "size %d", sz)
+
+		// The final frame header has the last flag set.
+		hdr := binary.LittleEndian.Uint32(c)
+		require.Equal(nFrames == 1, hdr&frameLastFlag != 0, "size %d: first header", sz)
+
+		p, err := decrypt(c)
+		require.NoError(err, "decrypt: size %d", sz)
+		require.Equal(m[:sz], p, "decrypt: size %d", sz)
+
+		// Trailing data is ignored.
+		p, err = decrypt(append(append([]byte{}, c...), 0, 1, 2, 3))
+		require.NoError(err, "decrypt(trailing): size %d", sz)
+		require.Equal(m[:sz], p, "decrypt(trailing): size %d", sz)
+
+		_, err = decrypt(c[:len(c)-1])
+		require.Equal(ErrTruncatedStream, err, "decrypt(truncated): size %d", sz)
+		if nFrames > 1 {
+			_, err = decrypt(c[:frameHeaderSize+frameChunkSize+TagSize])
+			require.Equal(ErrTruncatedStream, err, "decrypt(dropped frame): size %d", sz)
+		}
+
+		tampered := append([]byte{}, c...)
+		tampered[len(tampered)-1] ^= 1
+		_, err = decrypt(tampered)
+		require.Equal(ErrOpen, err, "decrypt(tampered): size %d", sz)
+	}
+
+	// Flipping the last flag fails authentication.
+	c := encrypt(m[:100])
+	c[frameHeaderSize-1] ^= 0x80
+	_, err := decrypt(append(c, make([]byte, frameHeaderSize)...))
+	require.Error(err, "decrypt(flag cleared)")
+
+	// Malformed frame headers are rejected.
+	for _, sealedLen := range []uint32{0, TagSize - 1, frameChunkSize + TagSize + 1} {
+		var hdr [frameHeaderSize]byte
+		binary.LittleEndian.PutUint32(hdr[:], sealedLen|frameLastFlag)
+		_, err = decrypt(hdr[:])
+		require.Equal(ErrInvalidFrame, err, "decrypt(length %d)", sealedLen)
+	}
+	_, err = decrypt(nil)
+	require.Equal(ErrTruncatedStream, err, "decrypt(empty)")
+
+	_, err = NewWriter(io.Discard, key[:], nonce[1:])
+	require.Equal(ErrInvalidNonceSize, err, "NewWriter(short nonce)")
+	_, err = NewReader(bytes.NewReader(nil), nil, nonce[:])
+	require.Equal(ErrInvalidKeySize, err, "NewReader(no key)")
+}
@@ -0,0 +1,135 @@
+// hash.go - HS1 standalone hash
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+)
+
+// HashSize is the size of a HS1 digest in bytes.
+const HashSize = hs1SIVLen
+
+var zeroNonce [NonceSize]byte
+
+// Schedule is a HS1 key schedule, suitable for computing many digests under
+// the same key. The (comparatively) expensive key expansion is done once by
+// NewSchedule, and each hash.Hash returned by New shares it.
+//
+// A Schedule is safe for concurrent use by multiple goroutines.
+type Schedule struct {
+	ks keySchedule
+}
+
+// New returns a new hash.Hash computing the keyed HS1 digest.
+//
+// The digest is the SIV that HS1-SIV would derive for the message, with
+// no additional data and an all zero nonce, and thus is a PRF rather than
+// a bare universal hash.
+func (s *Schedule) New() hash.Hash {
+	d := &digest{
+		ctx: aeadCtx{keySchedule: &s.ks},
+	}
+	d.Reset()
+	return d
+}
+
+// NewSchedule returns a new HS1 key schedule for the provided key, which
+// may be any size accepted by New. It panics with ErrInvalidKeySize if the
+// key is an invalid size.
+func NewSchedule(key []byte) *Schedule {
+	if !isValidKeySize(len(key)) {
+		panic(ErrInvalidKeySize)
+	}
+
+	s := new(Schedule)
+	if err := s.ks.setup(key, &paramsHi); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// NewHash returns a new hash.Hash computing the keyed HS1 digest under key,
+// as with NewSchedule(key).New(). Callers computing many digests under the
+// same key should use a Schedule instead.
+func NewHash(key []byte) hash.Hash {
+	return NewSchedule(key).New()
+}
+
+// MAC returns the HS1 digest of data under key, as computed by a hash.Hash
+// from NewSchedule(key).New(), for use as a one-shot message authentication
+// code. Tags should be checked with VerifyMAC.
+func MAC(key, data []byte) []byte {
+	h := NewSchedule(key).New()
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// VerifyMAC returns true iff tag is the HS1 digest of data under key, as
+// computed by a hash.Hash from NewSchedule(key).New(). The comparison is
+// constant time, and no heap allocations are made. False is returned if the
+// key is a size that NewSchedule would reject.
+func VerifyMAC(key, data, tag []byte) bool {
+	if !isValidKeySize(len(key)) || len(tag) != HashSize {
+		return false
+	}
+
+	var ks keySchedule
+	if err := ks.setup(key, &paramsHi); err != nil {
+		return false
+	}
+	d := digest{
+		ctx: aeadCtx{keySchedule: &ks},
+	}
+	d.Reset()
+	_, _ = d.Write(data)
+
+	var expected [HashSize]byte
+	d.sivSum(zeroNonce[:], expected[:])
+	return subtle.ConstantTimeCompare(expected[:], tag) == 1
+}
+
+type digest struct {
+	ctx    aeadCtx
+	nb     nhBuffer
+	mBytes uint64
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	d.mBytes += uint64(len(p))
+	d.nb.write(&d.ctx, p)
+	return len(p), nil
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	ret, out := sliceForAppend(b, HashSize)
+	d.sivSum(zeroNonce[:], out)
+	return ret
+}
+
+func (d *digest) sivSum(n, siv []byte) {
+	// Work with a copy, so that the caller can keep writing.
+	ctx := d.ctx
+	binary.LittleEndian.PutUint64(ctx.sivLenBuf[8:16], d.mBytes)
+	ctx.sivFinalize(d.nb.pending(), n, siv)
+}
+
+func (d *digest) Reset() {
+	d.ctx.sivSetup(0, 0)
+	d.nb.reset()
+	d.mBytes = 0
+}
+
+func (d *digest) Size() int {
+	return HashSize
+}
+
+func (d *digest) BlockSize() int {
+	return hs1NHLen
+}
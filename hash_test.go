@@ -0,0 +1,204 @@
+// hash_test.go - HS1 standalone hash tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+	s := NewSchedule(key[:])
+
+	var msg [1024]byte
+	_, _ = rand.Read(msg[:])
+
+	for i := range msg {
+		m := msg[:i]
+
+		// The digest is the SIV for (m, nil) under the zero nonce.
+		sealed := aead.Seal(nil, zeroNonce[:], m, nil)
+		expected := sealed[i:]
+
+		h := s.New()
+		require.Equal(HashSize, h.Size(), "Size()")
+		_, _ = h.Write(m)
+		require.Equal(expected, h.Sum(nil), "Sum(): %d", i)
+
+		// Sum must not alter the state.
+		require.Equal(expected, h.Sum(nil), "Sum() again: %d", i)
+
+		// Writes split at arbitrary points must give the same result.
+		h.Reset()
+		for off := 0; off < len(m); {
+			n := 1 + (off*7)%97
+			if off+n > len(m) {
+				n = len(m) - off
+			}
+			_, _ = h.Write(m[off : off+n])
+			off += n
+		}
+		require.Equal(expected, h.Sum(nil), "Sum(split): %d", i)
+	}
+}
+
+func TestNewHash(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	s := NewSchedule(key[:])
+
+	var msg [300]byte
+	_, _ = rand.Read(msg[:])
+
+	h := NewHash(key[:])
+	require.Equal(HashSize, h.Size(), "Size()")
+	require.Equal(hs1NHLen, h.BlockSize(), "BlockSize()")
+	for _, sz := range []int{0, 1, 64, 65, 300} {
+		expected := s.New()
+		_, _ = expected.Write(msg[:sz])
+
+		h.Reset()
+		_, _ = h.Write(msg[:sz])
+		require.Equal(expected.Sum(nil), h.Sum(nil), "Sum(): %d", sz)
+	}
+
+	require.Panics(func() { NewHash(nil) }, "NewHash(empty key)")
+}
+
+func TestMAC(t *testing.T) {
+	require := require.New(t)
+
+	// The MAC is the SIV for (m, nil) under the zero nonce, so the "known
+	// good" values were generated by this implementation, and are checked
+	// against the tags produced by Seal, which is covered by TestKAT.
+	w, _, k, _ := katInputs()
+	aead := New(k[:])
+
+	katHash := sha256.New()
+	for i := range w {
+		tag := MAC(k[:], w[:i])
+		require.Len(tag, HashSize, "MAC(): len %d", i)
+		require.Equal(aead.Seal(nil, zeroNonce[:], w[:i], nil)[i:], tag, "MAC(): %d", i)
+		if i == 0 {
+			require.Equal(katMACEmpty, hex.EncodeToString(tag), "MAC(): empty")
+		}
+		_, _ = katHash.Write(tag)
+
+		require.True(VerifyMAC(k[:], w[:i], tag), "VerifyMAC(): %d", i)
+	}
+	require.Equal(katMACDigest, hex.EncodeToString(katHash.Sum(nil)), "MAC(): digest")
+
+	// Every single bit flip of the tag is rejected.
+	tag := MAC(k[:], w[:])
+	for i := 0; i < len(tag)*8; i++ {
+		badTag := append([]byte{}, tag...)
+		badTag[i/8] ^= 1 << (i % 8)
+		require.False(VerifyMAC(k[:], w[:], badTag), "VerifyMAC(bit %d)", i)
+	}
+}
+
+func TestVerifyMAC(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	s := NewSchedule(key[:])
+
+	for _, sz := range []int{0, 1, 15, 16, 63, 64, 65, 1000} {
+		data := make([]byte, sz)
+		_, _ = rand.Read(data)
+
+		h := s.New()
+		_, _ = h.Write(data)
+		tag := h.Sum(nil)
+
+		require.True(VerifyMAC(key[:], data, tag), "VerifyMAC(): %d", sz)
+		allocs := testing.AllocsPerRun(10, func() {
+			VerifyMAC(key[:], data, tag)
+		})
+		require.Zero(allocs, "VerifyMAC(): %d: allocations", sz)
+
+		for _, off := range []int{0, HashSize - 1} {
+			badTag := append([]byte{}, tag...)
+			badTag[off] ^= 0x01
+			require.False(VerifyMAC(key[:], data, badTag), "VerifyMAC(bad tag %d): %d", off, sz)
+		}
+		if sz > 0 {
+			require.False(VerifyMAC(key[:], data[1:], tag), "VerifyMAC(truncated data): %d", sz)
+		}
+		require.False(VerifyMAC(key[:], data, tag[1:]), "VerifyMAC(short tag): %d", sz)
+		require.False(VerifyMAC(key[1:], data, tag), "VerifyMAC(truncated key): %d", sz)
+	}
+}
+
+func BenchmarkVerifyMAC(b *testing.B) {
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+
+	for _, sz := range []int{64, 1536} {
+		data := make([]byte, sz)
+		_, _ = rand.Read(data)
+		h := NewSchedule(key[:]).New()
+		_, _ = h.Write(data)
+		tag := h.Sum(nil)
+
+		b.Run(fmt.Sprintf("%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !VerifyMAC(key[:], data, tag) {
+					b.Fatal("VerifyMAC() failed")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSchedule(b *testing.B) {
+	benchSizes := []int{8, 32, 64, 576, 1536}
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+
+	for _, sz := range benchSizes {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		var out [HashSize]byte
+
+		b.Run(fmt.Sprintf("NewSchedule_%d", sz), func(b *testing.B) {
+			b.SetBytes(int64(sz))
+			for i := 0; i < b.N; i++ {
+				h := NewSchedule(key[:]).New()
+				_, _ = h.Write(m)
+				h.Sum(out[:0])
+			}
+		})
+		b.Run(fmt.Sprintf("Reused_%d", sz), func(b *testing.B) {
+			s := NewSchedule(key[:])
+			b.SetBytes(int64(sz))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h := s.New()
+				_, _ = h.Write(m)
+				h.Sum(out[:0])
+			}
+		})
+	}
+}
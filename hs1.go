@@ -12,6 +12,9 @@ import (
 	"math/bits"
 )
 
+// The hs1-siv-hi parameters, which are the default, and also the largest of
+// the standard parameter sets, so all of the fixed size buffers are sized
+// for them.
 const (
 	hs1NHLen      = 64 // Parameter b
 	hs1HashRounds = 6  // Parameter t
@@ -23,10 +26,53 @@ const (
 	hashStateSize = (hs1NHLen/4+4*(hs1HashRounds-1))*4 + hs1HashRounds*8 + hs1HashRounds*3*8
 )
 
+// hashOutputSize returns the size of the HS1 hash output in bytes, for the
+// given number of hash rounds. Past 4 rounds, each round's polynomial hash
+// result is compressed to 4 bytes by the ASU hash, otherwise each round
+// contributes the 8 byte polynomial hash result.
+func hashOutputSize(hashRounds int) int {
+	if hashRounds > 4 {
+		return 4 * hashRounds
+	}
+	return 8 * hashRounds
+}
+
+// hashStateSizeFor returns the size of the HS1 hash key material in bytes,
+// for the given number of hash rounds. The NH key grows by 16 bytes, and the
+// polynomial hash key by 8 bytes per round. The ASU hash key (24 bytes per
+// round) is only required past 4 rounds.
+func hashStateSizeFor(hashRounds int) int {
+	sz := (hs1NHLen/4+4*(hashRounds-1))*4 + hashRounds*8
+	if hashRounds > 4 {
+		sz += hashRounds * 3 * 8
+	}
+	return sz
+}
+
+// Constant time analysis:
+//
+// No part of HS1-SIV performs secret dependent memory accesses or branches.
+//
+//  * NH indexes nhKey and the input by loop counters, and the only other
+//    operations are 32 bit additions and 32x32->64 bit multiplies.
+//  * polyStep is a 64x64->128 bit multiply (bits.Mul64, constant time on
+//    all supported targets), shifts, masks, and additions.
+//  * polyFinalize does the final conditional subtraction with a mask
+//    derived from the sign bit, rather than a branch.
+//  * asuHash is multiplies and additions, with a fixed key offset.
+//  * The key schedule (setup) reads the ChaCha20 keystream at fixed
+//    offsets, and ChaCha20 itself is additions, rotates, and XORs.
+//  * Everything else only branches on or indexes by lengths, which are
+//    public, and tags are compared with subtle.ConstantTimeCompare.
+//
+// TestConstantTimeIndexing enforces the indexing part of this.
+
 type hs1Ctx struct {
 	nhKey   [hs1NHLen/4 + 4*(hs1HashRounds-1)]uint32
 	polyKey [hs1HashRounds]uint64
 	asuKey  [hs1HashRounds * 3]uint64
+
+	hashRounds int
 }
 
 // Return 63 bits congruent to ak+b mod (2^61-1).  Assume 60-bit k,b 62-bit a.
@@ -59,7 +105,7 @@ func hashStep(ctx *hs1Ctx, in []byte, accum *[hs1HashRounds]uint64) {
 			mp1 := binary.LittleEndian.Uint32(in[4:8])
 			mp2 := binary.LittleEndian.Uint32(in[8:12])
 			mp3 := binary.LittleEndian.Uint32(in[12:16])
-			for j := 0; j < hs1HashRounds; j += 2 {
+			for j := 0; j < ctx.hashRounds; j += 2 {
 				kp := ctx.nhKey[i+j*4:]
 				_ = kp[7] // Bounds check elimination.
 
@@ -70,7 +116,7 @@ func hashStep(ctx *hs1Ctx, in []byte, accum *[hs1HashRounds]uint64) {
 			}
 			in = in[16:]
 		}
-		for j := 0; j < hs1HashRounds; j += 2 {
+		for j := 0; j < ctx.hashRounds; j += 2 {
 			accum[j] = polyStep(accum[j], nhRes[j]&m60, ctx.polyKey[j])
 			accum[j+1] = polyStep(accum[j+1], nhRes[j+1]&m60, ctx.polyKey[j+1])
 		}
@@ -90,7 +136,7 @@ func hashFinalize(ctx *hs1Ctx, in []byte, accum *[hs1HashRounds]uint64, result [
 			mp2 := binary.LittleEndian.Uint32(in[8:12])
 			mp3 := binary.LittleEndian.Uint32(in[12:16])
 			in = in[16:]
-			for j := 0; j < hs1HashRounds; j += 2 {
+			for j := 0; j < ctx.hashRounds; j += 2 {
 				kp := ctx.nhKey[i+j*4:]
 				_ = kp[7] // Bounds check elimination.
 
@@ -100,12 +146,18 @@ func hashFinalize(ctx *hs1Ctx, in []byte, accum *[hs1HashRounds]uint64, result [
 				nhRes[j+1] += uint64(mp1+kp[5]) * uint64(mp3+kp[7])
 			}
 		}
-		for j := 0; j < hs1HashRounds; j += 2 {
+		for j := 0; j < ctx.hashRounds; j += 2 {
 			accum[j] = polyStep(accum[j], nhRes[j]&m60, ctx.polyKey[j])
 			accum[j+1] = polyStep(accum[j+1], nhRes[j+1]&m60, ctx.polyKey[j+1])
 		}
 	}
-	for j := 0; j < hs1HashRounds; j += 2 {
+	if ctx.hashRounds <= 4 {
+		for j := 0; j < ctx.hashRounds; j++ {
+			binary.LittleEndian.PutUint64(result[j*8:], polyFinalize(accum[j]))
+		}
+		return
+	}
+	for j := 0; j < ctx.hashRounds; j += 2 {
 		s0 := asuHash(polyFinalize(accum[j]), ctx.asuKey[3*j:])
 		s1 := asuHash(polyFinalize(accum[j+1]), ctx.asuKey[3*j+3:])
 		binary.LittleEndian.PutUint32(result[j*4:], s0)
@@ -0,0 +1,192 @@
+// hs1_test.go - HS1 hash function tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashOutputSize(t *testing.T) {
+	require := require.New(t)
+
+	for _, v := range []struct {
+		name       string
+		hashRounds int
+		size       int
+		stateSize  int
+	}{
+		{"hs1-siv-lo", 2, 16, 128},
+		{"hs1-siv-med", 4, 32, 176},
+		{"hs1-siv-hi", 6, 24, 368},
+	} {
+		require.Equal(v.size, hashOutputSize(v.hashRounds), "hashOutputSize(%s)", v.name)
+		require.Equal(v.stateSize, chacha20KeySize+hashStateSizeFor(v.hashRounds), "hashStateSizeFor(%s)", v.name)
+	}
+	require.Equal(hashStateSize, hashStateSizeFor(hs1HashRounds), "hashStateSizeFor()")
+	require.Equal(maxStateSize, New(make([]byte, KeySize)).StateSize(), "StateSize()")
+
+	// The ChaCha key is the hash output XORed into the prefix, with the
+	// remainder copied.
+	var dst, src [chacha20KeySize]byte
+	for i := range dst {
+		dst[i] = 0xff
+		src[i] = byte(i)
+	}
+	xorCopyChaChaKey(dst[:], src[:], hashOutputSize(hs1HashRounds))
+	n := hashOutputSize(hs1HashRounds)
+	for i := range dst {
+		if i < n {
+			require.Equal(^src[i], dst[i], "xorCopyChaChaKey(): XOR %d", i)
+		} else {
+			require.Equal(src[i], dst[i], "xorCopyChaChaKey(): copy %d", i)
+		}
+	}
+}
+
+func randUint64(mask uint64) uint64 {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:]) & mask
+}
+
+func TestPolyArithmetic(t *testing.T) {
+	require := require.New(t)
+
+	const (
+		m62 = (1 << 62) - 1
+		m63 = (1 << 63) - 1
+	)
+	p := new(big.Int).SetUint64(m61)
+	ref := func(a, b, k uint64) uint64 {
+		x := new(big.Int).SetUint64(a)
+		x.Mul(x, new(big.Int).SetUint64(k))
+		x.Add(x, new(big.Int).SetUint64(b))
+		return x.Mod(x, p).Uint64()
+	}
+
+	// polyStep returns a 63 bit value congruent to a*k+b mod 2^61-1, for
+	// a 60 bit k and b, and a 62 bit a. hashStep feeds the unreduced
+	// result back in as a, so a full 63 bit a must also work.
+	checkStep := func(a, b, k uint64) {
+		r := polyStep(a, b, k)
+		require.Zero(r>>63, "polyStep(%x, %x, %x): %x > 63 bits", a, b, k, r)
+		require.Equal(ref(a, b, k), r%m61, "polyStep(%x, %x, %x)", a, b, k)
+	}
+	edges60 := []uint64{0, 1, m60 - 1, m60}
+	edgesA := []uint64{0, 1, m61 - 1, m61, m61 + 1, m62, m63}
+	for _, a := range edgesA {
+		for _, b := range edges60 {
+			for _, k := range edges60 {
+				checkStep(a, b, k)
+			}
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		checkStep(randUint64(m62), randUint64(m60), randUint64(m60))
+		checkStep(randUint64(m63), randUint64(m60), randUint64(m60))
+	}
+
+	// Chaining polyStep as hashStep does, and reducing with polyFinalize
+	// matches the reference.
+	k := randUint64(m60)
+	a, expected := uint64(1), uint64(1)
+	for i := 0; i < 1000; i++ {
+		b := randUint64(m60)
+		a = polyStep(a, b, k)
+		expected = ref(expected, b, k)
+	}
+	require.Equal(expected, polyFinalize(a), "polyFinalize(chained)")
+
+	// polyFinalize fully reduces mod 2^61-1, including at the boundaries
+	// where the final conditional subtraction is (or is not) required.
+	checkFinalize := func(a uint64) {
+		expected := new(big.Int).SetUint64(a)
+		require.Equal(expected.Mod(expected, p).Uint64(), polyFinalize(a), "polyFinalize(%x)", a)
+	}
+	for _, a := range []uint64{
+		0, 1, m61 - 1, m61, m61 + 1, m61 + 2,
+		2*m61 - 1, 2 * m61, 2*m61 + 1,
+		1 << 61, 1 << 62, m62, m63, 1 << 63, ^uint64(0),
+	} {
+		checkFinalize(a)
+	}
+	for i := 0; i < 10000; i++ {
+		checkFinalize(randUint64(^uint64(0)))
+	}
+}
+
+func benchmarkHashCtx() *hs1Ctx {
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+
+	var ks keySchedule
+	if err := ks.setup(key[:], &paramsHi); err != nil {
+		panic(err)
+	}
+	return &ks.hashCtx
+}
+
+func BenchmarkHashFinalize(b *testing.B) {
+	ctx := benchmarkHashCtx()
+
+	var in [hs1NHLen]byte
+	var result [chacha20KeySize]byte
+	_, _ = rand.Read(in[:])
+
+	// The per-message fixed cost of finalization, isolated from NH and the
+	// polynomial accumulation, in the same order as hashFinalize.
+	b.Run("ASU", func(b *testing.B) {
+		var accum [hs1HashRounds]uint64
+		for i := range accum {
+			accum[i] = uint64(i) + 1
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var s uint32
+			for j := 0; j < hs1HashRounds; j++ {
+				s ^= asuHash(polyFinalize(accum[j]), ctx.asuKey[3*j:])
+			}
+			accum[0] += uint64(s)
+		}
+	})
+
+	// The entire finalization for the smallest (length block only) and
+	// largest final input.
+	for _, sz := range []int{16, hs1NHLen} {
+		b.Run(fmt.Sprintf("%d", sz), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				accum := [hs1HashRounds]uint64{1, 1, 1, 1, 1, 1}
+				hashFinalize(ctx, in[:sz], &accum, result[:])
+			}
+		})
+	}
+}
+
+func BenchmarkHashStep(b *testing.B) {
+	ctx := benchmarkHashCtx()
+
+	var in [hs1NHLen]byte
+	_, _ = rand.Read(in[:])
+	accum := [hs1HashRounds]uint64{1, 1, 1, 1, 1, 1}
+
+	b.SetBytes(hs1NHLen)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hashStep(ctx, in[:], &accum)
+	}
+}
@@ -7,30 +7,50 @@
 
 // Package hs1siv implements the HS1-SIV Authenticated Cipher.
 //
-// While the specification defines multiple parameter sets, this implementation
-// deliberately only supprorts the most conservative "hs1-siv-hi".
+// The specification defines multiple parameter sets. New uses the most
+// conservative "hs1-siv-hi", NewMed uses "hs1-siv-med", and NewWithParams
+// allows any supported parameter set to be used. Unless noted otherwise, the
+// package level helpers (TagSize, ValidateStructure, etc) assume
+// "hs1-siv-hi".
 //
 // This implementation is derived from the reference implementation by Ted
 // Krovetz.
 package hs1siv
 
 import (
+	"crypto/cipher"
 	"crypto/subtle"
 	"encoding/binary"
 	"errors"
+	"runtime"
+	"sync"
+	"time"
 )
 
 const (
-	// KeySize is the size of a key in bytes.
+	// KeySize is the size of a 256 bit key in bytes, which is the default
+	// and the largest supported key size.
 	KeySize = 32
 
+	// KeySize128 is the size of a 128 bit key in bytes, as used by the
+	// reference implementation.
+	KeySize128 = 16
+
 	// NonceSize is the size of a nonce in bytes.
 	NonceSize = 12
 
 	// TagSize is the size of an authentication tag in bytes.
 	TagSize = 32
 
-	stateSize = chacha20KeySize + hashStateSize
+	// MaxPlaintextSize is the size of the largest plaintext in bytes that
+	// can be sealed, just under 256 GiB. The message is encrypted with a
+	// 32 bit ChaCha20 block counter starting at 1 (block 0 under a
+	// different key is used to derive the SIV), which leaves 2^32 - 1 64
+	// byte blocks of keystream before the counter would wrap.
+	MaxPlaintextSize = ((1 << 32) - 1) * 64
+
+	// maxStateSize is the size of the largest expanded key schedule.
+	maxStateSize = chacha20KeySize + hashStateSize
 )
 
 var (
@@ -42,32 +62,58 @@ var (
 	// an invalid size.
 	ErrInvalidNonceSize = errors.New("hs1siv: invalid nonce size")
 
+	// ErrInvalidTagSize is the error returned when a tag is an invalid
+	// size.
+	ErrInvalidTagSize = errors.New("hs1siv: invalid tag size")
+
+	// ErrInvalidCiphertextSize is the error returned when a ciphertext is
+	// an invalid size.
+	ErrInvalidCiphertextSize = errors.New("hs1siv: invalid ciphertext size")
+
 	// ErrOpen is the error returned when the message authentication fails
 	// during an Open call.
 	ErrOpen = errors.New("hs1siv: message authentication failed")
 
-	settings = [chacha20NonceSize]byte{
-		0, 0, hs1SIVLen, 0, chacha20Rounds, hs1HashRounds, hs1NHLen,
-		0, 0, 0, 0,
-	}
+	// ErrKeyReset is the error thrown via a panic when an instance is used
+	// after Reset has been called.
+	ErrKeyReset = errors.New("hs1siv: instance has been reset")
+
 	zero [hs1SIVLen]byte
 )
 
 // AEAD is a HS1-SIV instance, implementing crypto/cipher.AEAD.
+//
+// An AEAD is safe for concurrent use by multiple goroutines, including
+// calls to Reset.
 type AEAD struct {
-	key []byte
+	// mu guards the key material against Reset. Operations hold the read
+	// lock for as long as they use the key.
+	mu    sync.RWMutex
+	wiped bool
+
+	key   []byte
+	keyID uint32
+	p     *Params
+
+	// ks is the key schedule, expanded once by the constructor rather than
+	// for every operation.
+	ks *keySchedule
+
+	newHash func([]byte) UniversalHash
+	uhKey   [KeySize]byte
 }
 
 // NonceSize returns the size of the nonce that must be passed to Seal and
-// Open.
+// Open. This is the ChaCha nonce size, and is the same for all parameter
+// sets.
 func (ae *AEAD) NonceSize() int {
 	return NonceSize
 }
 
 // Overhead returns the maximum difference between the lengths of a plaintext
-// and its ciphertext.
+// and its ciphertext, which is the SIVLen of the parameter set.
 func (ae *AEAD) Overhead() int {
-	return TagSize
+	return ae.p.SIVLen
 }
 
 // Seal encrypts and authenticates plaintext, authenticates the
@@ -75,16 +121,38 @@ func (ae *AEAD) Overhead() int {
 // slice. The nonce must be NonceSize() bytes long and should be unique for
 // all time, for a given key.
 //
+// HS1-SIV is nonce misuse resistant. Should a nonce be repeated, sealing
+// an identical plaintext and additional data will produce identical output,
+// and sealing anything else will produce unrelated output. The only thing
+// leaked is if two messages sealed under the same nonce were identical.
+//
+// Sealing an empty plaintext with empty additional data is supported, and
+// yields just the tag, which is a PRF of the nonce under the key.
+//
 // The plaintext and dst must overlap exactly or not at all. To reuse
 // plaintext's storage for the encrypted output, use plaintext[:0] as dst.
+// The additional data is hashed before any output is written, and may
+// alias either.
+//
+// Seal panics with ErrInvalidPlaintextSize if the plaintext is larger than
+// MaxPlaintextSize.
 func (ae *AEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 	if len(nonce) != NonceSize {
 		panic(ErrInvalidNonceSize)
 	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
 
-	var ctx aeadCtx
-	ctx.setup(ae.key)
-	ret, out := sliceForAppend(dst, len(plaintext)+TagSize)
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+	ret, out := sliceForAppend(dst, len(plaintext)+ae.p.SIVLen)
 	ctx.encrypt(plaintext, additionalData, nonce, out)
 	return ret
 }
@@ -98,9 +166,48 @@ func (ae *AEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 // The ciphertext and dst must overlap exactly or not at all. To reuse
 // ciphertext's storage for the decrypted output, use ciphertext[:0] as dst.
 //
+// On success, the returned slice is never nil, even if both dst and the
+// plaintext are empty. A ciphertext shorter than Overhead() bytes, or too
+// large to have been produced by Seal, fails with ErrOpen.
+//
 // Even if the function fails, the contents of dst, up to its capacity,
 // may be overwritten.
 func (ae *AEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return ae.open(dst, nonce, ciphertext, additionalData, false)
+}
+
+// OpenFused is identical to Open, except that decryption and authentication
+// are interleaved, so that each chunk of the plaintext is hashed while it is
+// still in cache, rather than making two full passes over the message.
+func (ae *AEAD) OpenFused(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return ae.open(dst, nonce, ciphertext, additionalData, true)
+}
+
+// SealWithError is identical to Seal, except that it returns
+// ErrInvalidNonceSize if the nonce is an invalid size, and
+// ErrInvalidPlaintextSize if the plaintext is larger than
+// MaxPlaintextSize, rather than panicking.
+func (ae *AEAD) SealWithError(dst, nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		return nil, err
+	}
+	return ae.Seal(dst, nonce, plaintext, additionalData), nil
+}
+
+// OpenWithError is identical to Open, except that it returns
+// ErrInvalidNonceSize if the nonce is an invalid size, rather than
+// panicking.
+func (ae *AEAD) OpenWithError(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	return ae.Open(dst, nonce, ciphertext, additionalData)
+}
+
+func (ae *AEAD) open(dst, nonce, ciphertext, additionalData []byte, fused bool) ([]byte, error) {
 	var err error
 	var ok bool
 
@@ -108,10 +215,24 @@ func (ae *AEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, err
 		panic(ErrInvalidNonceSize)
 	}
 
-	var ctx aeadCtx
-	ctx.setup(ae.key)
-	ret, out := sliceForAppend(dst, len(ciphertext)-TagSize)
-	ok = ctx.decrypt(ciphertext, additionalData, nonce, out)
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	if !ae.isValidCiphertextSize(len(ciphertext)) {
+		return nil, ErrOpen
+	}
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return nil, err
+	}
+	ctx := ae.newCtx(ks)
+	ret, out := sliceForAppend(dst, len(ciphertext)-ae.p.SIVLen)
+	if fused {
+		ok = ctx.decryptFused(ciphertext, additionalData, nonce, out)
+	} else {
+		ok = ctx.decrypt(ciphertext, additionalData, nonce, out)
+	}
 	if !ok {
 		// On decryption failures, purge the invalid plaintext.
 		if len(out) > 0 {
@@ -121,67 +242,471 @@ func (ae *AEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, err
 			ret = nil
 		}
 		err = ErrOpen
+	} else if ret == nil {
+		ret = []byte{}
 	}
 	return ret, err
 }
 
-// New returns a new keyed HS1-SIV instance.
+// StateSize returns the size in bytes of the expanded key schedule (the
+// ChaCha20 key and the HS1 hash key material) that is derived from the key
+// when the instance is created, and retained alongside it until Reset.
+//
+// The size depends on the parameter set, and is 128 bytes for hs1-siv-lo,
+// 176 bytes for hs1-siv-med and 368 bytes for hs1-siv-hi.
+func (ae *AEAD) StateSize() int {
+	return ae.p.stateSize()
+}
+
+// Params returns the parameter set used by the instance.
+func (ae *AEAD) Params() Params {
+	return *ae.p
+}
+
+// CompatibleWith returns true iff ciphertexts produced by ae and other are
+// interchangeable given the same key, that is, they use the same parameter
+// set, nonce and tag sizes, and hash. The keys are not compared.
+//
+// Instances using a custom UniversalHash are never considered compatible
+// with those using the HS1 hash, and as the hashes can not be compared,
+// are assumed to be compatible with each other.
+func (ae *AEAD) CompatibleWith(other *AEAD) bool {
+	return ae.NonceSize() == other.NonceSize() &&
+		*ae.p == *other.p &&
+		(ae.newHash == nil) == (other.newHash == nil)
+}
+
+// BlocksConsumed returns the number of 64 byte ChaCha20 blocks that Seal or
+// Open will generate for a plaintext of plaintextLen bytes. This is one
+// block (counter 0) under the SIV derivation key, and one block per 64
+// bytes of plaintext (counter 1 onward) under the SIV-derived message key.
+//
+// The blocks used to expand the user key are not included, as that cost is
+// per key rather than per message.
+func BlocksConsumed(plaintextLen int) int {
+	return 1 + (plaintextLen+63)/64
+}
+
+// New returns a new keyed HS1-SIV instance, using the hs1-siv-hi parameter
+// set.
+//
+// The key may be any length from 1 to KeySize bytes, as allowed by the
+// specification, though keys shorter than KeySize128 bytes are not
+// recommended. Only New, NewWithError, NewMed, NewWithParams and NewAEAD
+// accept keys shorter than KeySize bytes.
+//
+// New panics with ErrInvalidKeySize if the key is an invalid size, use
+// NewWithError if the key is not known to be valid ahead of time.
 func New(key []byte) *AEAD {
-	if len(key) != KeySize {
+	return newWithParams(key, &paramsHi)
+}
+
+// NewWithError returns a new keyed HS1-SIV instance as with New, or
+// ErrInvalidKeySize if the key is an invalid size.
+func NewWithError(key []byte) (*AEAD, error) {
+	if !isValidKeySize(len(key)) {
+		return nil, ErrInvalidKeySize
+	}
+	return New(key), nil
+}
+
+// NewMed returns a new keyed HS1-SIV instance, using the hs1-siv-med
+// parameter set (b = 64, t = 4, l = 16, r = 12), which has a 16 byte tag.
+//
+// The med parameter set is considerably faster than the default, at the
+// cost of a security margin that is considered adequate by the designer
+// rather than conservative. Ciphertexts are not interchangeable between
+// parameter sets.
+func NewMed(key []byte) *AEAD {
+	return newWithParams(key, &paramsMed)
+}
+
+// NewTruncated returns a new keyed HS1-SIV instance, using the hs1-siv-hi
+// parameter set with the SIV (and thus the tag) truncated to tagSize bytes,
+// or an error if the key or tag size is invalid. tagSize must be between 8
+// and TagSize bytes inclusive.
+//
+// The key schedule is that of hs1-siv-hi, so the truncated tag is the first
+// tagSize bytes of the TagSize byte tag that New would produce for the same
+// key, nonce, additional data and plaintext. The rest of the ciphertext
+// differs, as the message key is derived from the truncated SIV, and a
+// ciphertext will only authenticate under the tag size that it was sealed
+// with.
+//
+// Truncation trades security for bandwidth. An attacker can forge a
+// message with probability of roughly 2^-(8 * tagSize) per attempt, and
+// as the SIV is also the encryption IV, if a nonce is reused, distinct
+// messages will reuse the keystream with probability of roughly
+// q^2 / 2^(8 * tagSize + 1) after q messages, rather than only identical
+// messages being detectable. A 16 byte tag is adequate for most purposes,
+// shorter tags require limiting the number of forgery attempts (eg: by
+// rekeying after a small number of authentication failures). As the tags
+// are prefixes of each other, a key should only be used with one tag size.
+func NewTruncated(key []byte, tagSize int) (*AEAD, error) {
+	if tagSize < 8 || tagSize > TagSize {
+		return nil, ErrInvalidTagSize
+	}
+	p := paramsHi
+	if tagSize < p.SIVLen {
+		p.SIVLen, p.truncatedFrom = tagSize, p.SIVLen
+	}
+	return NewWithParams(key, p)
+}
+
+// NewWithParams returns a new keyed HS1-SIV instance using an arbitrary
+// parameter set, or an error if the key size or parameter set is invalid.
+//
+// This is intended for experimentation, and the standard parameter sets
+// (ParamsHi, ParamsMed, ParamsLo) should be preferred.
+func NewWithParams(key []byte, p Params) (*AEAD, error) {
+	if !isValidKeySize(len(key)) {
+		return nil, ErrInvalidKeySize
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return newWithParams(key, &p), nil
+}
+
+func newWithParams(key []byte, p *Params) *AEAD {
+	if !isValidKeySize(len(key)) {
 		panic(ErrInvalidKeySize)
 	}
-	return &AEAD{key: append([]byte{}, key...)}
+	ae := &AEAD{
+		key: append([]byte{}, key...),
+		p:   p,
+	}
+	return ae.expandKey()
 }
 
-type aeadCtx struct {
+// NewAEAD returns a new keyed HS1-SIV instance as a crypto/cipher.AEAD, or
+// ErrInvalidKeySize if the key is an invalid size, in the manner of
+// golang.org/x/crypto/chacha20poly1305.New.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	if !isValidKeySize(len(key)) {
+		return nil, ErrInvalidKeySize
+	}
+	return New(key), nil
+}
+
+// Wipe zeroes the key material held by the instance. Wipe blocks until
+// all in-flight operations have completed, and any subsequent use of the
+// instance will panic with ErrKeyReset.
+//
+// Readers returned by SealReader that have already been created are not
+// affected, as they hold their own copy of the expanded key.
+//
+// Instances that are garbage collected without being explicitly wiped are
+// wiped by a finalizer, but as there is no guarantee as to when (or if)
+// that happens, callers that care about key material lingering in memory
+// should call Wipe as soon as the instance is no longer needed.
+func (ae *AEAD) Wipe() {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	memwipe(ae.key)
+	memwipe(ae.uhKey[:])
+	if ae.ks != nil {
+		*ae.ks = keySchedule{}
+		ae.ks = nil
+	}
+	ae.wiped = true
+}
+
+// Reset is an alias for Wipe.
+func (ae *AEAD) Reset() {
+	ae.Wipe()
+}
+
+// Clone returns an independent copy of the instance, with the same key,
+// parameter set, and configuration, that shares no mutable state with the
+// original, so wiping one does not affect the other. The expanded key
+// schedule is copied rather than recomputed.
+//
+// As an AEAD is already safe for concurrent use, Clone is only needed when
+// the copies must have independent lifetimes. Clone panics with
+// ErrKeyReset if the instance has been wiped.
+func (ae *AEAD) Clone() *AEAD {
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	c := &AEAD{
+		key:     append([]byte{}, ae.key...),
+		keyID:   ae.keyID,
+		p:       ae.p,
+		newHash: ae.newHash,
+		uhKey:   ae.uhKey,
+	}
+	if ae.ks != nil {
+		ks := *ae.ks
+		c.ks = &ks
+	}
+	runtime.SetFinalizer(c, (*AEAD).Wipe)
+	return c
+}
+
+// expandKey expands and caches the key schedule, registers Wipe as a
+// finalizer, and returns ae. An unusable key is not cached, so that the
+// error is returned by each operation instead.
+func (ae *AEAD) expandKey() *AEAD {
+	ks := new(keySchedule)
+	if err := ks.setup(ae.key, ae.p); err == nil {
+		ae.ks = ks
+	}
+	runtime.SetFinalizer(ae, (*AEAD).Wipe)
+	return ae
+}
+
+// schedule returns the key schedule. The caller MUST hold the read lock for
+// as long as the key schedule is in use, and MUST NOT modify it.
+func (ae *AEAD) schedule() (*keySchedule, error) {
+	if ae.ks != nil {
+		return ae.ks, nil
+	}
+	ks := new(keySchedule)
+	if err := ks.setup(ae.key, ae.p); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// isValidCiphertextSize returns true iff a ciphertext of n bytes (including
+// the tag) could have been produced by Seal.
+func (ae *AEAD) isValidCiphertextSize(n int) bool {
+	return n >= ae.p.SIVLen && checkPlaintextSize(uint64(n-ae.p.SIVLen)) == nil
+}
+
+// rLock acquires the read lock guarding the key material, and panics if
+// the instance has been Reset.
+func (ae *AEAD) rLock() {
+	ae.mu.RLock()
+	if ae.wiped {
+		ae.mu.RUnlock()
+		panic(ErrKeyReset)
+	}
+}
+
+// keySchedule is the expanded key material derived from a user key. It is
+// never modified after setup, and is safe to share across goroutines.
+type keySchedule struct {
 	chachaKey [chacha20KeySize]byte
 	hashCtx   hs1Ctx
+	p         *Params
+}
+
+// NewTyped returns a new keyed HS1-SIV instance, bound to typeLabel.
+//
+// The label is used to derive a distinct subkey from key, so ciphertexts
+// sealed by instances with different labels will never open under each
+// other, regardless of the nonce and additional data. The key sizes
+// accepted are the same as with New.
+func NewTyped(key, typeLabel []byte) *AEAD {
+	if !isValidKeySize(len(key)) {
+		panic(ErrInvalidKeySize)
+	}
+
+	var subKey [KeySize]byte
+	if err := deriveKey(key, kdfPurposeTyped, typeLabel, &subKey); err != nil {
+		panic(err)
+	}
+	ae := &AEAD{
+		key: subKey[:],
+		p:   &paramsHi,
+	}
+	return ae.expandKey()
+}
+
+type aeadCtx struct {
+	*keySchedule
+
+	// uh, if non-nil, is used in place of the HS1 hash.
+	uh *uhAdapter
 
 	sivAccum  [hs1HashRounds]uint64
 	sivLenBuf [16]byte
 }
 
-// XOR first n bytes of src into dst, then copy the next 32-n bytes.
-func xorCopyChaChaKey(dst, src []byte) {
-	const n = 24 // For 6 hash rounds.
+// newCtx returns a new per-operation context, using the key schedule ks.
+func (ae *AEAD) newCtx(ks *keySchedule) aeadCtx {
+	ctx := aeadCtx{keySchedule: ks}
+	if ae.newHash != nil {
+		// Give the hash a copy of the key, so that it is not affected by
+		// Reset.
+		hashKey := ae.uhKey
+		ctx.uh = newUHAdapter(ae.newHash(hashKey[:]))
+	}
+	return ctx
+}
 
+// XOR first n bytes of src into dst, then copy the next 32-n bytes, where n
+// is the size of the hash output.
+func xorCopyChaChaKey(dst, src []byte, n int) {
 	for i, v := range src[:n] {
 		dst[i] ^= v
 	}
 	copy(dst[n:], src[n:])
 }
 
-func (ctx *aeadCtx) setup(userKey []byte) {
-	// The paper allows a variable length key of up to 256 bits, the reference
-	// implementation hard codes a 128 bit key.
+// maxPlaintextSize is MaxPlaintextSize, as a variable so that the tests can
+// exercise the size checks without allocating hundreds of GiB.
+var maxPlaintextSize uint64 = MaxPlaintextSize
+
+// checkPlaintextSize returns ErrInvalidPlaintextSize iff a plaintext of n
+// bytes is larger than MaxPlaintextSize.
+func checkPlaintextSize(n uint64) error {
+	if n > maxPlaintextSize {
+		return ErrInvalidPlaintextSize
+	}
+	return nil
+}
+
+// isValidKeySize returns true iff n is a supported user key size.
+func isValidKeySize(n int) bool {
+	return n > 0 && n <= KeySize
+}
+
+// expandUserKey returns the ChaCha key used for key expansion, which is the
+// user key repeated as many times as needed to fill chacha20KeySize bytes.
+// The length of the user key is separately bound by the key expansion nonce.
+//
+// Keys of an unsupported size are returned as is, so that the ChaCha
+// instantiation fails.
+func expandUserKey(userKey []byte, chachaKey *[chacha20KeySize]byte) []byte {
+	if !isValidKeySize(len(userKey)) || len(userKey) == chacha20KeySize {
+		return userKey
+	}
+	for i := 0; i < chacha20KeySize; i += len(userKey) {
+		copy(chachaKey[i:], userKey)
+	}
+	return chachaKey[:]
+}
+
+func (ks *keySchedule) setup(userKey []byte, p *Params) error {
+	// The paper allows a variable length key of up to 256 bits, which is
+	// repeated to fill the ChaCha key.
 	//
-	// This implementation only supports a 256 bit key.
-	var chachaNonce [chacha20NonceSize]byte
-	copy(chachaNonce[:], settings[:])
-	chachaNonce[0] = byte(len(userKey))
-	var buf [stateSize]byte
-	chacha20(userKey, chachaNonce[:], buf[:], buf[:], 0)
+	// This implementation supports the full range of key sizes.
+	chachaNonce := p.settings(len(userKey))
+	var expandedKey [chacha20KeySize]byte
+	var buf [maxStateSize]byte
+	stateLen := p.stateSize()
+	err := chacha(p.ChaChaRounds, expandUserKey(userKey, &expandedKey), chachaNonce[:], buf[:stateLen], buf[:stateLen], 0)
+	memwipe(expandedKey[:])
+	if err != nil {
+		memwipe(buf[:])
+		return err
+	}
+	ks.p = p
+	ks.hashCtx.hashRounds = p.HashRounds
 
 	off := chacha20KeySize
-	copy(ctx.chachaKey[:], buf[:off])
-	for i := range ctx.hashCtx.nhKey {
-		ctx.hashCtx.nhKey[i] = binary.LittleEndian.Uint32(buf[off:])
+	copy(ks.chachaKey[:], buf[:off])
+	for i := range ks.hashCtx.nhKey[:hs1NHLen/4+4*(p.HashRounds-1)] {
+		ks.hashCtx.nhKey[i] = binary.LittleEndian.Uint32(buf[off:])
 		off += 4
 	}
-	for i := range ctx.hashCtx.polyKey {
-		ctx.hashCtx.polyKey[i] = binary.LittleEndian.Uint64(buf[off:]) & m60
+	for i := range ks.hashCtx.polyKey[:p.HashRounds] {
+		ks.hashCtx.polyKey[i] = binary.LittleEndian.Uint64(buf[off:]) & m60
 		off += 8
 	}
-	for i := range ctx.hashCtx.asuKey {
-		ctx.hashCtx.asuKey[i] = binary.LittleEndian.Uint64(buf[off:])
-		off += 8
+	if p.HashRounds > 4 {
+		for i := range ks.hashCtx.asuKey[:3*p.HashRounds] {
+			ks.hashCtx.asuKey[i] = binary.LittleEndian.Uint64(buf[off:])
+			off += 8
+		}
+	}
+	memwipe(buf[:stateLen])
+	return nil
+}
+
+// The SIV is derived from the HS1 hash of:
+//
+//   A || zero-pad(A, b) || M || zero-pad(M, 16) || LE64(|A|) || LE64(|M|)
+//
+// where the AD is always hashed before the message, and the AD length always
+// precedes the message length. The hashing is split across sivSetup,
+// sivHashAD, and sivGenerate/sivFinalize, which MUST be called in that order.
+
+func (ctx *aeadCtx) hashSize() int {
+	if ctx.uh != nil {
+		return ctx.uh.h.Size()
+	}
+	return hashOutputSize(ctx.p.HashRounds)
+}
+
+func (ctx *aeadCtx) absorb(in []byte) {
+	// len(in) MUST be a multiple of hs1NHLen.
+	if ctx.uh != nil {
+		ctx.uh.step(in)
+		return
+	}
+	hashStep(&ctx.hashCtx, in, &ctx.sivAccum)
+}
+
+func (ctx *aeadCtx) finalize(in, result []byte) {
+	if ctx.uh != nil {
+		ctx.uh.finalize(in, result)
+		return
+	}
+	hashFinalize(&ctx.hashCtx, in, &ctx.sivAccum, result)
+}
+
+// nhBuffer buffers input to the hash across multiple writes, so that only
+// whole NH blocks are absorbed, as required by absorb.
+type nhBuffer struct {
+	buf [hs1NHLen]byte
+	n   int
+}
+
+// write absorbs p into ctx, buffering any trailing partial NH block.
+func (b *nhBuffer) write(ctx *aeadCtx, p []byte) {
+	if b.n > 0 {
+		cpLen := copy(b.buf[b.n:], p)
+		b.n += cpLen
+		p = p[cpLen:]
+		if b.n < hs1NHLen {
+			return
+		}
+		ctx.absorb(b.buf[:])
+		b.n = 0
 	}
+
+	nhMultiple := len(p) & ^(hs1NHLen - 1)
+	ctx.absorb(p[:nhMultiple])
+	b.n = copy(b.buf[:], p[nhMultiple:])
+}
+
+// pending returns the buffered partial NH block.
+func (b *nhBuffer) pending() []byte {
+	return b.buf[:b.n]
+}
+
+// flushPadded absorbs the buffered partial NH block, if any, zero padded to
+// a whole NH block.
+func (b *nhBuffer) flushPadded(ctx *aeadCtx) {
+	if b.n > 0 {
+		for i := range b.buf[b.n:] {
+			b.buf[b.n+i] = 0
+		}
+		ctx.absorb(b.buf[:])
+	}
+	b.n = 0
+}
+
+// reset clears the buffer.
+func (b *nhBuffer) reset() {
+	memwipe(b.buf[:])
+	b.n = 0
 }
 
 func (ctx *aeadCtx) sivSetup(aBytes, mBytes int) {
 	// Init: set up lengths, accumulator.
 	binary.LittleEndian.PutUint64(ctx.sivLenBuf[0:8], uint64(aBytes))
 	binary.LittleEndian.PutUint64(ctx.sivLenBuf[8:16], uint64(mBytes))
+	if ctx.uh != nil {
+		ctx.uh.h.Reset()
+		return
+	}
 	for i := range ctx.sivAccum {
 		ctx.sivAccum[i] = 1
 	}
@@ -192,85 +717,270 @@ func (ctx *aeadCtx) sivHashAD(a []byte) {
 
 	// Hash associated data.
 	nhMultiple := aBytes & ^(hs1NHLen - 1)
-	hashStep(&ctx.hashCtx, a[:nhMultiple], &ctx.sivAccum)
+	ctx.absorb(a[:nhMultiple])
 	if nhMultiple < aBytes {
 		var buf [hs1NHLen]byte
 		copy(buf[:], a[nhMultiple:])
-		hashStep(&ctx.hashCtx, buf[:], &ctx.sivAccum)
+		ctx.absorb(buf[:])
 	}
 }
 
 func (ctx *aeadCtx) sivGenerate(m, n, siv []byte) {
+	// Hash message data.
+	nhMultiple := len(m) & ^(hs1NHLen - 1)
+	ctx.absorb(m[:nhMultiple])
+	ctx.sivFinalize(m[nhMultiple:], n, siv)
+}
+
+// sivHashADVectored is sivHashAD with the additional data split across
+// fragments. Partial NH blocks are buffered across fragment boundaries, so
+// that only the end of the last fragment is zero padded.
+func (ctx *aeadCtx) sivHashADVectored(as [][]byte) {
+	var nb nhBuffer
+	for _, a := range as {
+		nb.write(ctx, a)
+	}
+	nb.flushPadded(ctx)
+	nb.reset()
+}
+
+func vectoredLen(bs [][]byte) int {
+	var n int
+	for _, b := range bs {
+		n += len(b)
+	}
+	return n
+}
+
+// sivGenerateVectored is sivGenerate with the message split across
+// fragments, buffering partial NH blocks across fragment boundaries.
+func (ctx *aeadCtx) sivGenerateVectored(ms [][]byte, n, siv []byte) {
+	var nb nhBuffer
+	for _, m := range ms {
+		nb.write(ctx, m)
+	}
+	ctx.sivFinalize(nb.pending(), n, siv)
+	nb.reset()
+}
+
+func (ctx *aeadCtx) sivFinalize(m, n, siv []byte) {
+	// len(m) MUST be less than hs1NHLen.
 	mBytes := len(m)
 
-	// Hash message data.
 	var chachaKey [chacha20KeySize]byte
-	nhMultiple := mBytes & ^(hs1NHLen - 1)
-	hashStep(&ctx.hashCtx, m[:nhMultiple], &ctx.sivAccum)
-	mBytes = mBytes - nhMultiple
 	mBytesWithPadding := (mBytes + 15) & ^15
 	if mBytesWithPadding == hs1NHLen {
 		var buf [hs1NHLen]byte
-		copy(buf[:], m[nhMultiple:])
-		hashStep(&ctx.hashCtx, buf[:], &ctx.sivAccum)
-		hashFinalize(&ctx.hashCtx, ctx.sivLenBuf[:], &ctx.sivAccum, chachaKey[:])
+		copy(buf[:], m)
+		ctx.absorb(buf[:])
+		ctx.finalize(ctx.sivLenBuf[:], chachaKey[:])
 	} else {
 		var buf [hs1NHLen]byte
-		copy(buf[:], m[nhMultiple:])
+		copy(buf[:], m)
 		copy(buf[mBytesWithPadding:], ctx.sivLenBuf[:])
-		hashFinalize(&ctx.hashCtx, buf[:mBytesWithPadding+16], &ctx.sivAccum, chachaKey[:])
+		ctx.finalize(buf[:mBytesWithPadding+16], chachaKey[:])
 	}
 
 	// Derive the SIV.
-	xorCopyChaChaKey(chachaKey[:], ctx.chachaKey[:])
-	chacha20(chachaKey[:], n, zero[:], siv, 0)
+	xorCopyChaChaKey(chachaKey[:], ctx.chachaKey[:], ctx.hashSize())
+	mustChaCha(ctx.p.ChaChaRounds, chachaKey[:], n, zero[:len(siv)], siv, 0)
+	memwipe(chachaKey[:])
+}
+
+func (ctx *aeadCtx) streamKey(siv, chachaKey []byte) {
+	// Derive the ChaCha20 key used to encrypt the message from the SIV.
+	if ctx.uh != nil {
+		ctx.uh.h.Reset()
+		ctx.uh.finalize(siv, chachaKey)
+	} else {
+		// The SIV is zero padded to a multiple of 16 bytes, as with the
+		// final block of any other input to the hash.
+		var sivBuf [hs1SIVLen]byte
+		copy(sivBuf[:], siv)
+		var accum [hs1HashRounds]uint64
+		for i := range accum {
+			accum[i] = 1
+		}
+		hashFinalize(&ctx.hashCtx, sivBuf[:(len(siv)+15)&^15], &accum, chachaKey)
+		memwipe(sivBuf[:])
+	}
+	xorCopyChaChaKey(chachaKey, ctx.chachaKey[:], ctx.hashSize())
 }
 
 func (ctx *aeadCtx) encrypt(m, a, n, c []byte) {
 	mBytes := len(m)
-	var accum [hs1HashRounds]uint64
-	for i := range accum {
-		accum[i] = 1
-	}
+	ctx.encryptDetached(m, a, n, c[:mBytes], c[mBytes:])
+}
 
-	var siv [hs1SIVLen]byte
-	ctx.sivSetup(len(a), len(m))
-	ctx.sivHashAD(a)
-	ctx.sivGenerate(m, n, siv[:])
+// encryptDetached is encrypt with the ciphertext and SIV (tag) written
+// separately. len(c) MUST be len(m), and len(tag) MUST be the SIV length.
+func (ctx *aeadCtx) encryptDetached(m, a, n, c, tag []byte) {
+	ctx.encryptVectored([][]byte{m}, [][]byte{a}, n, c, tag)
+}
+
+// encryptVectored is encryptDetached with the message and additional data
+// split across fragments. len(c) MUST be the total length of ms.
+func (ctx *aeadCtx) encryptVectored(ms, as [][]byte, n, c, tag []byte) {
+	aBytes, mBytes := vectoredLen(as), vectoredLen(ms)
+
+	t := statsStart()
+	ctx.sivSetup(aBytes, mBytes)
+	ctx.sivHashADVectored(as)
+	ctx.sealHashed(t, aBytes, ms, n, c, tag)
+}
+
+// sealHashed derives the SIV from the message ms, and encrypts it into c,
+// writing the SIV to tag. ctx MUST have been set up and have hashed the
+// additional data. t is the start time of the stage, and aBytes is the
+// length of the additional data, which are only used for the statistics.
+func (ctx *aeadCtx) sealHashed(t time.Time, aBytes int, ms [][]byte, n, c, tag []byte) {
+	mBytes := len(c)
+
+	var sivBuf [hs1SIVLen]byte
+	siv := sivBuf[:ctx.p.SIVLen]
+	ctx.sivGenerateVectored(ms, n, siv)
+	t = statsHash(t, aBytes+mBytes)
 
 	var chachaKey [chacha20KeySize]byte
-	hashFinalize(&ctx.hashCtx, siv[:], &accum, chachaKey[:])
-	xorCopyChaChaKey(chachaKey[:], ctx.chachaKey[:])
-	chacha20(chachaKey[:], n, m, c, 1)
-	copy(c[mBytes:], siv[:])
+	ctx.streamKey(siv, chachaKey[:])
+	if len(ms) == 1 {
+		mustChaCha(ctx.p.ChaChaRounds, chachaKey[:], n, ms[0], c, 1)
+	} else {
+		stream := mustNewChaCha(ctx.p.ChaChaRounds, chachaKey[:], n, 1)
+		off := 0
+		for _, m := range ms {
+			stream.XORKeyStream(c[off:off+len(m)], m)
+			off += len(m)
+		}
+	}
+	memwipe(chachaKey[:])
+	copy(tag, siv)
+	memwipe(sivBuf[:])
+	statsCipher(t, mBytes)
 }
 
 func (ctx *aeadCtx) decrypt(c, a, n, m []byte) bool {
-	cBytes := len(c)
-	if cBytes < hs1SIVLen {
+	return ctx.decryptVectored(c, [][]byte{a}, n, m)
+}
+
+// decryptVectored is decrypt with the additional data split across
+// fragments.
+func (ctx *aeadCtx) decryptVectored(c []byte, as [][]byte, n, m []byte) bool {
+	sivLen := ctx.p.SIVLen
+	if len(c) < sivLen || checkPlaintextSize(uint64(len(c)-sivLen)) != nil {
 		return false
 	}
-	mBytes := cBytes - hs1SIVLen
+	mBytes := len(c) - sivLen
+
+	var sivBuf, maybeSIVBuf [hs1SIVLen]byte
+	siv, maybeSIV := sivBuf[:sivLen], maybeSIVBuf[:sivLen]
+	copy(siv, c[mBytes:])
+	ctx.decryptDetachedVectored(c[:mBytes], siv, as, n, m, maybeSIV)
+	ok := subtle.ConstantTimeCompare(siv, maybeSIV) == 1
+	memwipe(sivBuf[:])
+	memwipe(maybeSIVBuf[:])
+	return ok
+}
 
-	var accum [hs1HashRounds]uint64
-	for i := range accum {
-		accum[i] = 1
+// decryptSIV decrypts c into m, and writes the SIV derived from the
+// resulting plaintext to maybeSIV, leaving the comparison against the SIV
+// in c to the caller. len(c) MUST be at least the SIV length.
+func (ctx *aeadCtx) decryptSIV(c, a, n, m, maybeSIV []byte) {
+	mBytes := len(c) - ctx.p.SIVLen
+	ctx.decryptDetached(c[:mBytes], c[mBytes:], a, n, m, maybeSIV)
+}
+
+// decryptDetached is decryptSIV with the ciphertext and SIV (tag) passed
+// separately. len(tag) and len(maybeSIV) MUST be the SIV length.
+func (ctx *aeadCtx) decryptDetached(c, tag, a, n, m, maybeSIV []byte) {
+	ctx.decryptDetachedVectored(c, tag, [][]byte{a}, n, m, maybeSIV)
+}
+
+// decryptDetachedVectored is decryptDetached with the additional data split
+// across fragments.
+func (ctx *aeadCtx) decryptDetachedVectored(c, tag []byte, as [][]byte, n, m, maybeSIV []byte) {
+	aBytes := vectoredLen(as)
+
+	var sivBuf [hs1SIVLen]byte
+	var nonce [NonceSize]byte
+	siv := sivBuf[:len(tag)]
+	copy(siv, tag)    // Work with a copy, `m` and `tag` may alias.
+	copy(nonce[:], n) // Work with a copy, `m` and `n` may alias.
+
+	t := statsStart()
+	var chachaKey [chacha20KeySize]byte
+	ctx.streamKey(siv, chachaKey[:])
+	memwipe(sivBuf[:])
+	t = statsCipher(t, 0)
+	ctx.sivSetup(aBytes, len(m))
+	ctx.sivHashADVectored(as) // Hash AD before decrption, `m` and `a` may alias.
+	t = statsHash(t, aBytes)
+	ctx.openKeyed(t, chachaKey[:], c, nonce[:], m, maybeSIV)
+}
+
+// openKeyed decrypts c into m with the message key chachaKey, which is
+// wiped, and writes the SIV derived from the resulting plaintext to
+// maybeSIV. ctx MUST have been set up and have hashed the additional data.
+// t is the start time of the stage, which is only used for the statistics.
+func (ctx *aeadCtx) openKeyed(t time.Time, chachaKey, c, n, m, maybeSIV []byte) {
+	mBytes := len(c)
+	mustChaCha(ctx.p.ChaChaRounds, chachaKey, n, c, m, 1)
+	memwipe(chachaKey)
+	t = statsCipher(t, mBytes)
+	ctx.sivGenerate(m, n, maybeSIV)
+	statsHash(t, mBytes)
+}
+
+func (ctx *aeadCtx) decryptFused(c, a, n, m []byte) bool {
+	// The size of each chunk that is decrypted then hashed, chosen to
+	// comfortably fit in the L1 cache.
+	const fusedChunkSize = 64 * hs1NHLen
+
+	cBytes, sivLen := len(c), ctx.p.SIVLen
+	if cBytes < sivLen || checkPlaintextSize(uint64(cBytes-sivLen)) != nil {
+		return false
 	}
+	mBytes := cBytes - sivLen
 
-	var siv, maybeSIV [hs1SIVLen]byte
+	var sivBuf, maybeSIVBuf [hs1SIVLen]byte
 	var nonce [NonceSize]byte
-	copy(siv[:], c[mBytes:])
+	siv, maybeSIV := sivBuf[:sivLen], maybeSIVBuf[:sivLen]
+	copy(siv, c[mBytes:])
 	copy(nonce[:], n) // Work with a copy, `m` and `n` may alias.
 
+	t := statsStart()
 	var chachaKey [chacha20KeySize]byte
-	hashFinalize(&ctx.hashCtx, siv[:], &accum, chachaKey[:])
-	xorCopyChaChaKey(chachaKey[:], ctx.chachaKey[:])
+	ctx.streamKey(siv, chachaKey[:])
+	t = statsCipher(t, 0)
 	ctx.sivSetup(len(a), len(m))
 	ctx.sivHashAD(a) // Hash AD before decrption, `m` and `a` may alias.
-	chacha20(chachaKey[:], nonce[:], c[:mBytes], m, 1)
-	ctx.sivGenerate(m, nonce[:], maybeSIV[:])
+	t = statsHash(t, len(a))
 
-	return subtle.ConstantTimeCompare(siv[:], maybeSIV[:]) == 1
+	// Unlike encryption, the keystream is known up front, so each chunk
+	// can be hashed immediately after it is decrypted.
+	stream := mustNewChaCha(ctx.p.ChaChaRounds, chachaKey[:], nonce[:], 1)
+	memwipe(chachaKey[:])
+	nhMultiple := mBytes & ^(hs1NHLen - 1)
+	for off := 0; off < nhMultiple; {
+		n := nhMultiple - off
+		if n > fusedChunkSize {
+			n = fusedChunkSize
+		}
+		stream.XORKeyStream(m[off:off+n], c[off:off+n])
+		t = statsCipher(t, n)
+		ctx.absorb(m[off : off+n])
+		t = statsHash(t, n)
+		off += n
+	}
+	stream.XORKeyStream(m[nhMultiple:], c[nhMultiple:mBytes])
+	t = statsCipher(t, mBytes-nhMultiple)
+	ctx.sivFinalize(m[nhMultiple:], nonce[:], maybeSIV)
+	statsHash(t, mBytes-nhMultiple)
+
+	ok := subtle.ConstantTimeCompare(siv, maybeSIV) == 1
+	memwipe(sivBuf[:])
+	memwipe(maybeSIVBuf[:])
+	return ok
 }
 
 // Shamelessly stolen from the Go runtime library.
@@ -5480,3 +5480,37 @@ var kaths1siv = []byte{
 	0xBC, 0x4A, 0x63, 0x5D, 0x39, 0xF0, 0x2E, 0x22,
 	0xA6, 0x14, 0xED, 0xBB, 0x82, 0xD3, 0xCF,
 }
+
+// The *Empty values are the hs1-siv-med, hs1-siv-lo, 128 bit key hs1-siv-hi,
+// and truncated tag hs1-siv-hi outputs for the empty message and AD, and the
+// corresponding *Digest values are the SHA-256 digests of all of the
+// concatenated KAT outputs. Apart from the truncated tag prefixes, they were
+// generated by this implementation, see the tests that use them.
+const (
+	katMedEmpty      = "eb7cd99d06e2b6b0127f336739f14ce8"
+	katMedDigest     = "446cbcfb359e15381d0d1336334b5fc034d46e3f571a328176b4cd97ff765d94"
+	katLoEmpty       = "dc050fe7e209c5d9"
+	katLoDigest      = "32d4b7965b6eb59b937dd39246177d9a8b345fb3122a467bc5da14f46dfd4e21"
+	kat128Empty      = "f935968781fa57296901e9865545ce28ed6b0e18d3189fc90674783c023cb0dd"
+	kat128Digest     = "bfe39dba9882677d669a7833d3ae29791fdda223f9283544ddbab16f33212e4f"
+	katTrunc16Empty  = "ad460e2a4d1f9d2d45a69b3b2caeb6bf"
+	katTrunc16Digest = "b0d9c77f5cca70c4ec6b95f0266e65e00aacdf70617390b982006c13900bac1c"
+	katTrunc24Empty  = "ad460e2a4d1f9d2d45a69b3b2caeb6bfb32f97f5d05997ac"
+	katTrunc24Digest = "89728aad5fef6744e9df00d02fc60ffe66006de126cbf2acbb671c2ad644eff7"
+)
+
+// katMACEmpty is the MAC of the empty message under the TestKAT key, and
+// katMACDigest is the SHA-256 digest of the concatenated MACs of each
+// prefix of the TestKAT message. See TestMAC for their provenance.
+const (
+	katMACEmpty  = "19c2591708c407258eba6a57572457f07b242a3789e591868e388c2a8ac1c57e"
+	katMACDigest = "7a9bc7ede1716a80f871b44ec9e44ae05d6ec39228886abb443b4ada47a2fbef"
+)
+
+// katXEmpty is the XHS1-SIV output for the empty message and AD, and
+// katXDigest is the SHA-256 digest of all of the concatenated KAT outputs.
+// See TestXHS1SIV for their provenance.
+const (
+	katXEmpty  = "273ab5e19341b1003c305d7d3641e64ef836bd577db3cf5e2f1dfacf241863a1"
+	katXDigest = "b529e9de14bbd2f288afe46a6c78df0f75382d238f33ce92e3a3729e5c3594c2"
+)
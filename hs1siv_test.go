@@ -10,23 +10,25 @@ package hs1siv
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
 
-func TestKAT(t *testing.T) {
-	require := require.New(t)
-
-	// There are no official test vectors, so the "known good" values used
-	// by this test were generated by combining `genkat.c` from the NORX
-	// source package and `supercop-20171218/crypto_aead/hs1sivhiv2/ref`.
-
-	var w, h [256]byte
-	var k [32]byte
-	var n [12]byte
-
+// katInputs returns the inputs used by `genkat.c` from the NORX source
+// package.
+func katInputs() (w, h [256]byte, k [32]byte, n [12]byte) {
 	for i := range w {
 		w[i] = byte(255 & (i*197 + 123))
 	}
@@ -39,6 +41,16 @@ func TestKAT(t *testing.T) {
 	for i := range n {
 		n[i] = byte(255 & (i*181 + 123))
 	}
+	return
+}
+
+func TestKAT(t *testing.T) {
+	require := require.New(t)
+
+	// There are no official test vectors, so the "known good" values used
+	// by this test were generated by combining `genkat.c` from the NORX
+	// source package and `supercop-20171218/crypto_aead/hs1sivhiv2/ref`.
+	w, h, k, n := katInputs()
 
 	var katAcc []byte
 	katOff := 0
@@ -59,6 +71,13 @@ func TestKAT(t *testing.T) {
 		if len(m) != 0 {
 			require.Equal(m, w[:i], "Open(): m %d", i)
 		}
+
+		m, err = aead.OpenFused(nil, n[:], c, h[:i])
+		require.NoError(err, "OpenFused(): %d", i)
+		require.Len(m, i, "OpenFused(): len(m) %d", i)
+		if len(m) != 0 {
+			require.Equal(m, w[:i], "OpenFused(): m %d", i)
+		}
 		katOff += len(c)
 
 		// Test malformed ciphertext.
@@ -67,6 +86,9 @@ func TestKAT(t *testing.T) {
 		m, err = aead.Open(nil, n[:], badC, h[:i])
 		require.Error(err, "Open(Bad c): %d", i)
 		require.Nil(m, "Open(Bad c): len(m) %d", i)
+		m, err = aead.OpenFused(nil, n[:], badC, h[:i])
+		require.Error(err, "OpenFused(Bad c): %d", i)
+		require.Nil(m, "OpenFused(Bad c): len(m) %d", i)
 
 		// Test malformed AD.
 		if i > 0 {
@@ -80,6 +102,1107 @@ func TestKAT(t *testing.T) {
 	require.Equal(kaths1siv, katAcc, "Final concatenated cipher texts.")
 }
 
+func TestMedRegression(t *testing.T) {
+	// Unlike TestKAT, the expected values used by this test were generated
+	// by this implementation, with the same inputs as TestKAT, and have not
+	// been verified against the reference implementation. They only detect
+	// regressions. See testdata/hs1sivhiv2/README.md for how to compare
+	// them with `crypto_aead/hs1sivmev2/ref`.
+	_, _, k, _ := katInputs()
+	testKATDigest(t, NewMed(k[:]), 16, katMedEmpty, katMedDigest)
+}
+
+// testKATDigest runs the TestKAT inputs through aead, and checks the output
+// for the empty message and AD, and the SHA-256 digest of the concatenated
+// outputs against the expected values.
+func testKATDigest(t *testing.T, aead *AEAD, tagSize int, expectedEmpty, expectedDigest string) {
+	require := require.New(t)
+
+	w, h, _, n := katInputs()
+
+	require.Equal(NonceSize, aead.NonceSize(), "NonceSize()")
+	require.Equal(tagSize, aead.Overhead(), "Overhead()")
+
+	katHash := sha256.New()
+	for i := range w {
+		c := aead.Seal(nil, n[:], w[:i], h[:i])
+		require.Len(c, i+tagSize, "Seal(): len(c) %d", i)
+		if i == 0 {
+			require.Equal(expectedEmpty, hex.EncodeToString(c), "Seal(): empty")
+		}
+		_, _ = katHash.Write(c)
+
+		m, err := aead.Open(nil, n[:], c, h[:i])
+		require.NoError(err, "Open(): %d", i)
+		require.Equal(w[:i], append([]byte{}, m...), "Open(): m %d", i)
+
+		m, err = aead.OpenFused(nil, n[:], c, h[:i])
+		require.NoError(err, "OpenFused(): %d", i)
+		require.Equal(w[:i], append([]byte{}, m...), "OpenFused(): m %d", i)
+
+		badC := append([]byte{}, c...)
+		badC[i] ^= 0x23
+		_, err = aead.Open(nil, n[:], badC, h[:i])
+		require.Error(err, "Open(Bad c): %d", i)
+	}
+	require.Equal(expectedDigest, hex.EncodeToString(katHash.Sum(nil)), "SHA-256 of concatenated cipher texts.")
+}
+
+func TestKey128Regression(t *testing.T) {
+	// As with TestMedRegression, these values were generated by this
+	// implementation, with the first 128 bits of the TestKAT key, and have
+	// not been verified against the reference implementation built with
+	// CRYPTO_KEYBYTES set to 16 (see testdata/hs1sivhiv2/README.md).
+	_, _, k, _ := katInputs()
+	testKATDigest(t, New(k[:KeySize128]), TagSize, kat128Empty, kat128Digest)
+}
+
+func TestKey128(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:KeySize128])
+	copy(key[KeySize128:], key[:KeySize128])
+	_, _ = rand.Read(nonce[:])
+	m := []byte("a message sealed with a 128 bit key")
+
+	aead := New(key[:KeySize128])
+	c := aead.Seal(nil, nonce[:], m, nil)
+	pt, err := aead.Open(nil, nonce[:], c, nil)
+	require.NoError(err, "Open()")
+	require.Equal(m, pt, "Open()")
+
+	// The key is expanded by repetition, but the key length is bound by
+	// the key schedule, so it is distinct from the repeated 256 bit key.
+	var expanded [chacha20KeySize]byte
+	require.Equal(key[:], expandUserKey(key[:KeySize128], &expanded), "expandUserKey()")
+	settings := paramsHi.settings(KeySize128)
+	require.EqualValues(KeySize128, settings[0], "settings(): key length")
+	want := paramsHi.settings(KeySize)
+	require.Equal(want[1:], settings[1:], "settings(): parameters")
+	require.NotEqual(New(key[:]).Seal(nil, nonce[:], m, nil), c, "Seal(): 128 vs 256 bit key")
+	_, err = New(key[:]).Open(nil, nonce[:], c, nil)
+	require.Equal(ErrOpen, err, "Open(): 256 bit key")
+
+	// The derived key features work with 128 bit keys.
+	require.NotEqual([KeySize]byte{}, aead.CommitmentKey(), "CommitmentKey()")
+}
+
+func TestVariableKey(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	m := []byte("a message sealed with a variable length key")
+
+	require.Panics(func() { New(nil) }, "New(empty key)")
+	require.Panics(func() { New(make([]byte, KeySize+1)) }, "New(long key)")
+
+	seen := make(map[string]bool)
+	for sz := 1; sz <= KeySize; sz++ {
+		aead := New(key[:sz])
+		c := aead.Seal(nil, nonce[:], m, nil)
+		pt, err := aead.Open(nil, nonce[:], c, nil)
+		require.NoError(err, "Open(): %d byte key", sz)
+		require.Equal(m, pt, "Open(): %d byte key", sz)
+
+		// Every prefix of the key is a distinct key.
+		require.False(seen[string(c)], "Seal(): %d byte key", sz)
+		seen[string(c)] = true
+	}
+
+	// The key is repeated to fill the ChaCha key, including when the key
+	// length does not evenly divide it.
+	var expanded [chacha20KeySize]byte
+	k := expandUserKey(key[:17], &expanded)
+	require.Equal(key[:17], k[:17], "expandUserKey(17)")
+	require.Equal(key[:15], k[17:], "expandUserKey(17)")
+	k = expandUserKey(key[:31], &expanded)
+	require.Equal(key[:31], k[:31], "expandUserKey(31)")
+	require.Equal(key[:1], k[31:], "expandUserKey(31)")
+	require.Equal(key[:], expandUserKey(key[:], &expanded), "expandUserKey(32)")
+}
+
+func TestKeySizeAgreement(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize + 1]byte
+	_, _ = rand.Read(key[:])
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+
+	// Every constructor accepts exactly the key sizes that New does.
+	for sz := 0; sz <= len(key); sz++ {
+		k := key[:sz]
+		valid := isValidKeySize(sz)
+		for name, fn := range map[string]func(){
+			"New":                  func() { New(k) },
+			"NewTyped":             func() { NewTyped(k, []byte("label")) },
+			"NewWithUniversalHash": func() { NewWithUniversalHash(k, newHash) },
+			"NewSchedule":          func() { NewSchedule(k) },
+			"MAC":                  func() { MAC(k, nil) },
+			"NewFixedSealer":       func() { NewFixedSealer(k, 0) },
+		} {
+			if valid {
+				require.NotPanics(fn, "%s(): %d byte key", name, sz)
+			} else {
+				require.PanicsWithValue(ErrInvalidKeySize, fn, "%s(): %d byte key", name, sz)
+			}
+		}
+		_, err := NewWithParams(k, ParamsHi())
+		require.Equal(valid, err == nil, "NewWithParams(): %d byte key", sz)
+
+		// VerifyMAC accepts the tags MAC produces for every valid key size,
+		// and is false for every invalid one.
+		tag := make([]byte, HashSize)
+		if valid {
+			tag = MAC(k, []byte("data"))
+		}
+		require.Equal(valid, VerifyMAC(k, []byte("data"), tag), "VerifyMAC(): %d byte key", sz)
+	}
+}
+
+func TestKATTruncated(t *testing.T) {
+	// The truncated tags are prefixes of the TestKAT tags, which come from
+	// the reference implementation. The rest of the ciphertext is derived
+	// from the truncated SIV, so the digests were generated by this
+	// implementation.
+	w, h, k, n := katInputs()
+	for _, v := range []struct {
+		tagSize       int
+		empty, digest string
+	}{
+		{16, katTrunc16Empty, katTrunc16Digest},
+		{24, katTrunc24Empty, katTrunc24Digest},
+	} {
+		aead, err := NewTruncated(k[:], v.tagSize)
+		require.NoError(t, err, "NewTruncated(%d)", v.tagSize)
+		testKATDigest(t, aead, v.tagSize, v.empty, v.digest)
+
+		katOff := 0
+		for i := range w {
+			c := aead.Seal(nil, n[:], w[:i], h[:i])
+			katOff += i
+			require.Equal(t, kaths1siv[katOff:katOff+v.tagSize], c[i:], "Seal(): %d tag %d", v.tagSize, i)
+			katOff += TagSize
+		}
+	}
+}
+
+func TestNewTruncated(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	m := []byte("a message with a truncated tag")
+
+	for _, sz := range []int{0, 7, TagSize + 1} {
+		aead, err := NewTruncated(key[:], sz)
+		require.Equal(ErrInvalidTagSize, err, "NewTruncated(%d)", sz)
+		require.Nil(aead, "NewTruncated(%d)", sz)
+	}
+	_, err := NewTruncated(nil, 16)
+	require.Equal(ErrInvalidKeySize, err, "NewTruncated(empty key)")
+
+	full := New(key[:]).Seal(nil, nonce[:], m, nil)
+	for _, sz := range []int{8, 16, 24, TagSize} {
+		aead, err := NewTruncated(key[:], sz)
+		require.NoError(err, "NewTruncated(%d)", sz)
+		require.Equal(sz, aead.Overhead(), "Overhead(): %d", sz)
+
+		c := aead.Seal(nil, nonce[:], m, nil)
+		require.Len(c, len(m)+sz, "Seal(): %d", sz)
+		pt, err := aead.Open(nil, nonce[:], c, nil)
+		require.NoError(err, "Open(): %d", sz)
+		require.Equal(m, pt, "Open(): %d", sz)
+
+		if sz == TagSize {
+			require.Equal(full, c, "Seal(): untruncated")
+			continue
+		}
+
+		// The truncated tag is a prefix of the full tag, but the message
+		// key is derived from the truncated SIV.
+		require.Equal(full[len(m):len(m)+sz], c[len(m):], "Seal(): tag prefix %d", sz)
+		require.NotEqual(full[:len(m)], c[:len(m)], "Seal(): ciphertext %d", sz)
+		require.False(aead.CompatibleWith(New(key[:])), "CompatibleWith(): %d", sz)
+		_, err = New(key[:]).Open(nil, nonce[:], append(c, full[len(m)+sz:]...), nil)
+		require.Equal(ErrOpen, err, "New().Open(extended): %d", sz)
+
+		// Every byte of the tag is checked.
+		badC := append([]byte{}, c...)
+		badC[len(badC)-1] ^= 0x01
+		_, err = aead.Open(nil, nonce[:], badC, nil)
+		require.Equal(ErrOpen, err, "Open(bad tag): %d", sz)
+
+		// Ciphertext shorter than the tag is rejected.
+		_, err = aead.Open(nil, nonce[:], c[:sz-1], nil)
+		require.Equal(ErrOpen, err, "Open(short): %d", sz)
+	}
+}
+
+func TestNewMed(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	m, ad := []byte("a message for the hs1-siv-med parameter set"), []byte("additional data")
+
+	med, hi := NewMed(key[:]), New(key[:])
+	require.Equal(16, med.Overhead(), "Overhead()")
+	require.Equal(chacha20KeySize+hashStateSizeFor(4), med.StateSize(), "StateSize()")
+
+	c := med.Seal(nil, nonce[:], m, ad)
+	require.Len(c, len(m)+16, "Seal()")
+	pt, err := med.Open(nil, nonce[:], c, ad)
+	require.NoError(err, "Open()")
+	require.Equal(m, pt, "Open()")
+
+	// Ciphertexts are not interchangeable between parameter sets, even
+	// with the same key.
+	cHi := hi.Seal(nil, nonce[:], m, ad)
+	require.NotEqual(cHi[:len(c)], c, "Seal(): med vs hi")
+	_, err = med.Open(nil, nonce[:], cHi, ad)
+	require.Equal(ErrOpen, err, "med.Open(hi)")
+	_, err = hi.Open(nil, nonce[:], c, ad)
+	require.Error(err, "hi.Open(med)")
+
+	require.Panics(func() { NewMed(key[:0]) }, "NewMed(empty key)")
+}
+
+func TestSIVOrdering(t *testing.T) {
+	require := require.New(t)
+
+	// specSIV derives the SIV by literally following the specification,
+	// building the entire hash input up front, so that it will catch
+	// the implementation transposing the AD and message, or their lengths.
+	specSIV := func(key, n, a, m []byte) []byte {
+		var ks keySchedule
+		require.NoError(ks.setup(key, &paramsHi), "setup()")
+
+		var s []byte
+		s = append(s, a...)
+		if rem := len(a) % hs1NHLen; rem != 0 {
+			s = append(s, make([]byte, hs1NHLen-rem)...)
+		}
+		s = append(s, m...)
+		if rem := len(m) % 16; rem != 0 {
+			s = append(s, make([]byte, 16-rem)...)
+		}
+		var lens [16]byte
+		binary.LittleEndian.PutUint64(lens[0:8], uint64(len(a)))
+		binary.LittleEndian.PutUint64(lens[8:16], uint64(len(m)))
+		s = append(s, lens[:]...)
+
+		// The final 1 to hs1NHLen bytes go to hashFinalize.
+		var accum [hs1HashRounds]uint64
+		for i := range accum {
+			accum[i] = 1
+		}
+		nFull := (len(s) - 1) / hs1NHLen * hs1NHLen
+		hashStep(&ks.hashCtx, s[:nFull], &accum)
+
+		var chachaKey [chacha20KeySize]byte
+		hashFinalize(&ks.hashCtx, s[nFull:], &accum, chachaKey[:])
+		xorCopyChaChaKey(chachaKey[:], ks.chachaKey[:], hashOutputSize(hs1HashRounds))
+
+		siv := make([]byte, hs1SIVLen)
+		require.NoError(chacha20(chachaKey[:], n, siv, siv, 0), "chacha20()")
+		return siv
+	}
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	var buf [300]byte
+	_, _ = rand.Read(buf[:])
+	for _, aLen := range []int{0, 1, 16, 63, 64, 65, 130} {
+		for _, mLen := range []int{0, 1, 15, 16, 47, 48, 49, 64, 65, 128, 170} {
+			a, m := buf[:aLen], buf[aLen:aLen+mLen]
+
+			c := aead.Seal(nil, nonce[:], m, a)
+			require.Equal(specSIV(key[:], nonce[:], a, m), c[mLen:], "SIV(%d, %d)", aLen, mLen)
+
+			// Swapping the AD and message must change the SIV.
+			if aLen != mLen || aLen != 0 {
+				c2 := aead.Seal(nil, nonce[:], a, m)
+				require.NotEqual(c[mLen:], c2[aLen:], "SIV(%d, %d) vs swapped", aLen, mLen)
+			}
+		}
+	}
+}
+
+func TestBlocksConsumed(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	var ks keySchedule
+	require.NoError(ks.setup(key[:], &paramsHi), "setup()")
+	ctx := aead.newCtx(&ks)
+
+	for _, sz := range []int{0, 1, 63, 64, 65, 128, 1000} {
+		nBlocks := BlocksConsumed(sz)
+		require.Equal(1+(sz+63)/64, nBlocks, "BlocksConsumed(%d)", sz)
+
+		m := make([]byte, sz)
+		c := aead.Seal(nil, nonce[:], m, nil)
+		siv := c[sz:]
+
+		// The message is encrypted with exactly nBlocks-1 blocks of
+		// keystream, starting at counter 1. The all-zero plaintext
+		// makes the ciphertext the keystream itself.
+		var chachaKey [chacha20KeySize]byte
+		ctx.streamKey(siv, chachaKey[:])
+		keystream := make([]byte, (nBlocks-1)*64)
+		require.NoError(chacha20(chachaKey[:], nonce[:], keystream, keystream, 1), "chacha20()")
+		require.Equal(keystream[:sz], c[:sz], "keystream(%d)", sz)
+		require.True(len(keystream)-sz < 64, "keystream(%d): unused blocks", sz)
+	}
+}
+
+func TestNonceReuse(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, err := rand.Read(key[:])
+	require.NoError(err, "rand.Read(key)")
+	_, err = rand.Read(nonce[:])
+	require.NoError(err, "rand.Read(nonce)")
+
+	aead := New(key[:])
+	m1 := []byte("Attack at dawn, bring snacks.")
+	m2 := []byte("Attack at dusk, bring snacks.")
+	ad := []byte("additional data")
+
+	// Identical inputs under a repeated nonce give identical output.
+	c1 := aead.Seal(nil, nonce[:], m1, ad)
+	c1Again := aead.Seal(nil, nonce[:], m1, ad)
+	require.Equal(c1, c1Again, "Seal(m1) twice")
+
+	// Different plaintexts under a repeated nonce give unrelated output,
+	// so XOR-ing the ciphertexts does not recover XOR-ing the plaintexts.
+	c2 := aead.Seal(nil, nonce[:], m2, ad)
+	require.NotEqual(c1, c2, "Seal(m1) vs Seal(m2)")
+	require.NotEqual(c1[len(m1):], c2[len(m2):], "Seal(m1) vs Seal(m2): tag")
+
+	cX, mX := make([]byte, len(m1)), make([]byte, len(m1))
+	for i := range m1 {
+		cX[i] = c1[i] ^ c2[i]
+		mX[i] = m1[i] ^ m2[i]
+	}
+	require.NotEqual(mX, cX, "c1 ^ c2 != m1 ^ m2")
+
+	// Different additional data also gives unrelated output.
+	c3 := aead.Seal(nil, nonce[:], m1, m2)
+	require.NotEqual(c1, c3, "Seal(m1, ad) vs Seal(m1, m2)")
+
+	// And all of them still open.
+	for _, v := range []struct {
+		c, m, ad []byte
+	}{
+		{c1, m1, ad},
+		{c2, m2, ad},
+		{c3, m1, m2},
+	} {
+		m, err := aead.Open(nil, nonce[:], v.c, v.ad)
+		require.NoError(err, "Open()")
+		require.Equal(v.m, m, "Open()")
+	}
+}
+
+func TestNilVsEmpty(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	cNil := aead.Seal(nil, nonce[:], nil, nil)
+	require.Len(cNil, TagSize, "Seal(nil, nil)")
+
+	cEmpty := aead.Seal([]byte{}, nonce[:], []byte{}, []byte{})
+	require.Equal(cNil, cEmpty, "Seal(empty, empty)")
+
+	cMixed := aead.Seal(nil, nonce[:], []byte{}, nil)
+	require.Equal(cNil, cMixed, "Seal(empty, nil)")
+	cMixed = aead.Seal(nil, nonce[:], nil, []byte{})
+	require.Equal(cNil, cMixed, "Seal(nil, empty)")
+
+	for _, ad := range [][]byte{nil, {}} {
+		m, err := aead.Open(nil, nonce[:], cNil, ad)
+		require.NoError(err, "Open()")
+		require.Len(m, 0, "Open()")
+	}
+}
+
+func TestNewAEAD(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	aead, err := NewAEAD(key[:])
+	require.NoError(err, "NewAEAD()")
+	require.Equal(NonceSize, aead.NonceSize(), "NonceSize()")
+	require.Equal(TagSize, aead.Overhead(), "Overhead()")
+
+	m := []byte("cipher.AEAD")
+	require.Equal(New(key[:]).Seal(nil, nonce[:], m, nil), aead.Seal(nil, nonce[:], m, nil), "Seal()")
+
+	for _, sz := range []int{0, KeySize + 1} {
+		aead, err = NewAEAD(make([]byte, sz))
+		require.Equal(ErrInvalidKeySize, err, "NewAEAD(%d)", sz)
+		require.Nil(aead, "NewAEAD(%d)", sz)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	for _, sz := range []int{1, KeySize128, KeySize} {
+		aead, err := NewWithError(key[:sz])
+		require.NoError(err, "NewWithError(%d)", sz)
+		m := []byte("NewWithError")
+		require.Equal(New(key[:sz]).Seal(nil, nonce[:], m, nil), aead.Seal(nil, nonce[:], m, nil), "Seal(): key size %d", sz)
+	}
+
+	for _, sz := range []int{0, KeySize + 1} {
+		aead, err := NewWithError(make([]byte, sz))
+		require.True(errors.Is(err, ErrInvalidKeySize), "NewWithError(%d)", sz)
+		require.Nil(aead, "NewWithError(%d)", sz)
+	}
+}
+
+func TestWithError(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m, ad := []byte("plaintext"), []byte("additional data")
+	c, err := aead.SealWithError(nil, nonce[:], m, ad)
+	require.NoError(err, "SealWithError()")
+	require.Equal(aead.Seal(nil, nonce[:], m, ad), c, "SealWithError()")
+
+	p, err := aead.OpenWithError(nil, nonce[:], c, ad)
+	require.NoError(err, "OpenWithError()")
+	require.Equal(m, p, "OpenWithError()")
+
+	c[0] ^= 1
+	_, err = aead.OpenWithError(nil, nonce[:], c, ad)
+	require.Equal(ErrOpen, err, "OpenWithError(): tampered")
+	c[0] ^= 1
+
+	for _, sz := range []int{0, NonceSize - 1, NonceSize + 1} {
+		badNonce := make([]byte, sz)
+		require.NotPanics(func() {
+			c, err := aead.SealWithError(nil, badNonce, m, ad)
+			require.True(errors.Is(err, ErrInvalidNonceSize), "SealWithError(): nonce size %d", sz)
+			require.Nil(c, "SealWithError(): nonce size %d", sz)
+			p, err := aead.OpenWithError(nil, badNonce, c, ad)
+			require.True(errors.Is(err, ErrInvalidNonceSize), "OpenWithError(): nonce size %d", sz)
+			require.Nil(p, "OpenWithError(): nonce size %d", sz)
+		}, "nonce size %d", sz)
+	}
+}
+
+func TestCompatibleWith(t *testing.T) {
+	require := require.New(t)
+
+	var key, otherKey [KeySize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(otherKey[:])
+
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+
+	aead := New(key[:])
+	require.True(aead.CompatibleWith(aead), "CompatibleWith(self)")
+	require.True(aead.CompatibleWith(New(otherKey[:])), "CompatibleWith(other key)")
+	require.True(aead.CompatibleWith(NewTyped(key[:], []byte("label"))), "CompatibleWith(typed)")
+	require.True(aead.CompatibleWith(NewWithKeyID(key[:], 23)), "CompatibleWith(key id)")
+	require.False(aead.CompatibleWith(NewMed(key[:])), "CompatibleWith(med)")
+	require.True(NewMed(key[:]).CompatibleWith(NewMed(otherKey[:])), "med CompatibleWith(med)")
+
+	uh := NewWithUniversalHash(key[:], newHash)
+	require.False(aead.CompatibleWith(uh), "CompatibleWith(universal hash)")
+	require.False(uh.CompatibleWith(aead), "universal hash CompatibleWith()")
+	require.True(uh.CompatibleWith(NewWithUniversalHash(otherKey[:], newHash)), "universal hash CompatibleWith(universal hash)")
+}
+
+func TestStreamCipherError(t *testing.T) {
+	require := require.New(t)
+
+	// The key expansion reports an unusable key as a typed error, rather
+	// than an opaque panic.
+	var ks keySchedule
+	err := ks.setup(make([]byte, KeySize+1), &paramsHi)
+	require.True(errors.Is(err, ErrStreamCipher), "setup(long key): %v", err)
+	err = ks.setup(nil, &paramsHi)
+	require.True(errors.Is(err, ErrStreamCipher), "setup(empty key): %v", err)
+
+	var subKey [KeySize]byte
+	err = deriveKey(make([]byte, KeySize+1), kdfPurposeTyped, nil, &subKey)
+	require.True(errors.Is(err, ErrStreamCipher), "deriveKey(long key): %v", err)
+
+	// Which Open propagates, and Seal throws via a panic.
+	aead := &AEAD{key: make([]byte, KeySize+1), p: &paramsHi}
+	var nonce [NonceSize]byte
+	_, err = aead.Open(nil, nonce[:], make([]byte, TagSize), nil)
+	require.True(errors.Is(err, ErrStreamCipher), "Open(long key): %v", err)
+	require.Panics(func() { aead.Seal(nil, nonce[:], nil, nil) }, "Seal(long key)")
+}
+
+func TestOpenShortCiphertext(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	// A ciphertext shorter than the tag is rejected with ErrOpen, rather
+	// than a panic, regardless of the capacity of dst.
+	for sz := 0; sz < TagSize; sz++ {
+		c := make([]byte, sz)
+		for _, dst := range [][]byte{nil, make([]byte, 0, 64), make([]byte, 8, 64)} {
+			m, err := aead.Open(dst, nonce[:], c, nil)
+			require.Equal(ErrOpen, err, "Open(): %d", sz)
+			require.Nil(m, "Open(): %d", sz)
+
+			m, err = aead.OpenFused(dst, nonce[:], c, nil)
+			require.Equal(ErrOpen, err, "OpenFused(): %d", sz)
+			require.Nil(m, "OpenFused(): %d", sz)
+		}
+	}
+
+	// The same goes for the rest of the API that opens sealed messages.
+	compressing, err := NewCompressingAEAD(aead, 1)
+	require.NoError(err, "NewCompressingAEAD()")
+	for _, sz := range []int{0, TagSize - 1} {
+		c := make([]byte, sz)
+		require.NotPanics(func() {
+			_, err = aead.OpenKeyed(nil, nonce[:], c, nil)
+			require.Error(err, "OpenKeyed(): %d", sz)
+			_, err = aead.OpenADNonce(nil, c, nil)
+			require.Error(err, "OpenADNonce(): %d", sz)
+			_, err = aead.OpenCommitted(nil, nonce[:], c, nil)
+			require.Error(err, "OpenCommitted(): %d", sz)
+			_, err = aead.OpenPadded(nil, nonce[:], c, nil)
+			require.Error(err, "OpenPadded(): %d", sz)
+			_, _, err = aead.OpenPartial(nil, nonce[:], c, 0)
+			require.Error(err, "OpenPartial(): %d", sz)
+			_, err = aead.OpenFields(nonce[:], c)
+			require.Error(err, "OpenFields(): %d", sz)
+			_, err = aead.OpenToWriter(io.Discard, nonce[:], c, nil)
+			require.Error(err, "OpenToWriter(): %d", sz)
+			_, err = aead.OpenReader(nonce[:], bytes.NewReader(c), int64(sz), nil)
+			require.Error(err, "OpenReader(): %d", sz)
+			_, err = aead.Reseal(nonce[:], c, nil, nonce[:], nil)
+			require.Error(err, "Reseal(): %d", sz)
+			_, err = compressing.Open(nil, nonce[:], c, nil)
+			require.Error(err, "CompressingAEAD.Open(): %d", sz)
+			_, err = aead.NewTranscript(nil).Open(nil, nonce[:], c, nil)
+			require.Error(err, "Transcript.Open(): %d", sz)
+			_, ok := aead.OpenUnsafe(nonce[:], c, nil)
+			require.False(ok, "OpenUnsafe(): %d", sz)
+			_, failures, err := aead.OpenBatch([][]byte{nonce[:]}, [][]byte{c}, nil)
+			require.NoError(err, "OpenBatch(): %d", sz)
+			require.Equal([]int{0}, failures, "OpenBatch(): %d", sz)
+		}, "short ciphertext: %d", sz)
+	}
+}
+
+func TestOversizedMessage(t *testing.T) {
+	require := require.New(t)
+
+	// Every entry point checks the length with checkPlaintextSize before
+	// touching the message.
+	require.NoError(checkPlaintextSize(0), "checkPlaintextSize(0)")
+	require.NoError(checkPlaintextSize(MaxPlaintextSize), "checkPlaintextSize(MaxPlaintextSize)")
+	require.Equal(ErrInvalidPlaintextSize, checkPlaintextSize(MaxPlaintextSize+1), "checkPlaintextSize(MaxPlaintextSize+1)")
+	require.Equal(ErrInvalidPlaintextSize, checkPlaintextSize(math.MaxUint64), "checkPlaintextSize(MaxUint64)")
+
+	// The largest allowed plaintext stops just short of the counter
+	// wrapping, given that the keystream starts at a counter of 1.
+	require.EqualValues(uint64(math.MaxUint32)*64, uint64(MaxPlaintextSize), "MaxPlaintextSize")
+
+	// As a backstop, ChaCha itself refuses to wrap the counter.
+	var key [chacha20KeySize]byte
+	var nonce [chacha20NonceSize]byte
+	buf := make([]byte, 2*chachaBlockSize)
+	for _, rounds := range []int{chacha20Rounds, paramsMed.ChaChaRounds} {
+		require.NoError(chacha(rounds, key[:], nonce[:], buf[:chachaBlockSize], buf[:chachaBlockSize], math.MaxUint32), "chacha(%d): last block", rounds)
+		require.NoError(chacha(rounds, key[:], nonce[:], buf, buf, math.MaxUint32-1), "chacha(%d): last 2 blocks", rounds)
+		err := chacha(rounds, key[:], nonce[:], buf[:chachaBlockSize+1], buf[:chachaBlockSize+1], math.MaxUint32)
+		require.Equal(ErrInvalidPlaintextSize, err, "chacha(%d): wrapped", rounds)
+		require.PanicsWithValue(ErrInvalidPlaintextSize, func() {
+			mustChaCha(rounds, key[:], nonce[:], buf, buf, math.MaxUint32)
+		}, "mustChaCha(%d): wrapped", rounds)
+	}
+}
+
+// setMaxPlaintextSize lowers the plaintext size limit enforced by
+// checkPlaintextSize to n bytes for the duration of the test, so that the
+// entry points can be tested with small messages.
+func setMaxPlaintextSize(t *testing.T, n uint64) {
+	old := maxPlaintextSize
+	maxPlaintextSize = n
+	t.Cleanup(func() { maxPlaintextSize = old })
+}
+
+func TestEmptyEverything(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce, otherNonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	_, _ = rand.Read(otherNonce[:])
+	aead := New(key[:])
+
+	tag := aead.Seal(nil, nonce[:], nil, nil)
+	require.Len(tag, TagSize, "Seal(nil, nil)")
+	require.NotEqual(tag, aead.Seal(nil, otherNonce[:], nil, nil), "Seal(nil, nil): differing nonce")
+
+	for _, open := range []func([]byte, []byte, []byte, []byte) ([]byte, error){
+		aead.Open,
+		aead.OpenFused,
+	} {
+		m, err := open(nil, nonce[:], tag, nil)
+		require.NoError(err, "Open(tag)")
+		require.NotNil(m, "Open(tag)")
+		require.Len(m, 0, "Open(tag)")
+
+		_, err = open(nil, otherNonce[:], tag, nil)
+		require.Equal(ErrOpen, err, "Open(tag, wrong nonce)")
+
+		badTag := append([]byte{}, tag...)
+		badTag[0] ^= 0x01
+		_, err = open(nil, nonce[:], badTag, nil)
+		require.Equal(ErrOpen, err, "Open(bad tag)")
+	}
+}
+
+func TestOpenInPlace(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	for _, sz := range []int{0, 1, 32, 63, 64, 65, 1000} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+
+		// Seal in place.
+		buf := make([]byte, sz, sz+TagSize)
+		copy(buf, m)
+		c := aead.Seal(buf[:0], nonce[:], buf, nil)
+		require.Equal(aead.Seal(nil, nonce[:], m, nil), c, "Seal(in place): %d", sz)
+
+		for _, fused := range []bool{false, true} {
+			buf := append([]byte{}, c...)
+			tag := append([]byte{}, c[sz:]...)
+
+			var d []byte
+			var err error
+			if fused {
+				d, err = aead.OpenFused(buf[:0], nonce[:], buf, nil)
+			} else {
+				d, err = aead.Open(buf[:0], nonce[:], buf, nil)
+			}
+			require.NoError(err, "Open(in place, %v): %d", fused, sz)
+			require.Equal(m, d, "Open(in place, %v): %d", fused, sz)
+			require.Equal(tag, buf[sz:], "Open(in place, %v): tag region %d", fused, sz)
+		}
+	}
+}
+
+func TestADAliasing(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	// The AD is always hashed before any output is written, so AD that
+	// aliases the plaintext or dst (including the region that the tag is
+	// written to) must give the same result as a separate copy.
+	for _, sz := range []int{1, 63, 64, 65, 1000} {
+		for _, v := range []struct {
+			name  string
+			adOff int
+			adLen int
+		}{
+			{"plaintext", 0, sz},
+			{"plaintext suffix", sz / 2, sz - sz/2},
+			{"tag", sz, TagSize},
+			{"plaintext and tag", 0, sz + TagSize},
+		} {
+			buf := make([]byte, sz+TagSize)
+			_, _ = rand.Read(buf)
+			m := append([]byte{}, buf[:sz]...)
+			ad := append([]byte{}, buf[v.adOff:v.adOff+v.adLen]...)
+
+			expected := aead.Seal(nil, nonce[:], m, ad)
+			c := aead.Seal(buf[:0], nonce[:], buf[:sz], buf[v.adOff:v.adOff+v.adLen])
+			require.Equal(expected, c, "Seal(%d, AD aliases %s)", sz, v.name)
+		}
+
+		// Open with the AD aliasing dst, which is overwritten by the
+		// plaintext.
+		m := make([]byte, sz)
+		out := make([]byte, sz)
+		_, _ = rand.Read(m)
+		_, _ = rand.Read(out)
+		c := aead.Seal(nil, nonce[:], m, out)
+		d, err := aead.Open(out[:0], nonce[:], c, out)
+		require.NoError(err, "Open(%d, AD aliases dst)", sz)
+		require.Equal(m, d, "Open(%d, AD aliases dst)", sz)
+	}
+}
+
+func TestConcurrentSealOpen(t *testing.T) {
+	// This is mostly useful when run with `-race`.
+	const (
+		nWorkers = 16
+		nIters   = 64
+	)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, nWorkers)
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+
+			var nonce [NonceSize]byte
+			m := make([]byte, 1+id*37)
+			ad := []byte(fmt.Sprintf("worker %d", id))
+			for j := 0; j < nIters; j++ {
+				_, _ = rand.Read(nonce[:])
+				_, _ = rand.Read(m)
+
+				c := aead.Seal(nil, nonce[:], m, ad)
+				d, err := aead.Open(nil, nonce[:], c, ad)
+				if err != nil {
+					errCh <- fmt.Errorf("worker %d: Open(): %w", id, err)
+					return
+				}
+				if !bytes.Equal(m, d) {
+					errCh <- fmt.Errorf("worker %d: Open(): plaintext mismatch", id)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+}
+
+func TestCachedKeySchedule(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+
+	for name, aead := range map[string]*AEAD{
+		"New":                  New(key[:]),
+		"NewMed":               NewMed(key[:]),
+		"NewTyped":             NewTyped(key[:], []byte("label")),
+		"NewWithUniversalHash": NewWithUniversalHash(key[:], newHash),
+		"New(128 bit key)":     New(key[:KeySize128]),
+	} {
+		// The constructor expands the key once, identically to expanding
+		// it per operation.
+		require.NotNil(aead.ks, "%s: ks", name)
+		var ks keySchedule
+		require.NoError(ks.setup(aead.key, aead.p), "%s: setup()", name)
+		require.Equal(ks, *aead.ks, "%s: ks", name)
+
+		cached := aead.ks
+		aead.Reset()
+		require.Nil(aead.ks, "%s: ks after Reset", name)
+		require.Equal(keySchedule{}, *cached, "%s: ks wiped by Reset", name)
+	}
+}
+
+func TestConcurrentReset(t *testing.T) {
+	// This is mostly useful when run with `-race`.
+	const nWorkers = 16
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := make([]byte, 1024)
+	_, _ = rand.Read(m)
+	expected := New(key[:]).Seal(nil, nonce[:], m, nil)
+
+	// Every operation must either complete with the original key, or
+	// panic with ErrKeyReset, and never observe a partially wiped key.
+	var wg sync.WaitGroup
+	errCh := make(chan error, nWorkers)
+	started := make(chan struct{}, nWorkers)
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil && r != ErrKeyReset {
+					errCh <- fmt.Errorf("worker %d: unexpected panic: %v", id, r)
+				}
+			}()
+
+			for j := 0; ; j++ {
+				if j == 0 {
+					started <- struct{}{}
+				}
+				c := aead.Seal(nil, nonce[:], m, nil)
+				if !bytes.Equal(expected, c) {
+					errCh <- fmt.Errorf("worker %d: Seal(): output mismatch", id)
+					return
+				}
+				if _, err := aead.Open(nil, nonce[:], c, nil); err != nil {
+					errCh <- fmt.Errorf("worker %d: Open(): %w", id, err)
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < nWorkers; i++ {
+		<-started
+	}
+	aead.Reset()
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+
+	require.PanicsWithValue(t, ErrKeyReset, func() { aead.Seal(nil, nonce[:], m, nil) }, "Seal() after Reset()")
+	require.PanicsWithValue(t, ErrKeyReset, func() { _, _ = aead.Open(nil, nonce[:], expected, nil) }, "Open() after Reset()")
+}
+
+func TestWipe(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	for i := range key {
+		key[i] = byte(i + 1)
+	}
+	var nonce [NonceSize]byte
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+
+	for name, aead := range map[string]*AEAD{
+		"New":                  New(key[:]),
+		"NewWithUniversalHash": NewWithUniversalHash(key[:], newHash),
+	} {
+		c := aead.Seal(nil, nonce[:], []byte("message"), nil)
+		userKey := aead.key
+
+		aead.Wipe()
+		require.Equal(make([]byte, KeySize), userKey, "%s: key after Wipe", name)
+		require.Equal([KeySize]byte{}, aead.uhKey, "%s: uhKey after Wipe", name)
+		require.Nil(aead.ks, "%s: ks after Wipe", name)
+		require.PanicsWithValue(ErrKeyReset, func() { aead.Seal(nil, nonce[:], nil, nil) }, "%s: Seal() after Wipe()", name)
+		require.PanicsWithValue(ErrKeyReset, func() { _, _ = aead.Open(nil, nonce[:], c, nil) }, "%s: Open() after Wipe()", name)
+
+		// Wiping is idempotent.
+		require.NotPanics(aead.Wipe, "%s: Wipe() twice", name)
+		require.NotPanics(aead.Reset, "%s: Reset() after Wipe()", name)
+	}
+}
+
+func TestClone(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+	m := []byte("cloned message")
+
+	for name, aead := range map[string]*AEAD{
+		"New":                  New(key[:]),
+		"NewMed":               NewMed(key[:KeySize128]),
+		"NewWithKeyID":         NewWithKeyID(key[:], 42),
+		"NewWithUniversalHash": NewWithUniversalHash(key[:], newHash),
+	} {
+		clone := aead.Clone()
+		require.True(aead.CompatibleWith(clone), "%s: CompatibleWith()", name)
+		require.Equal(aead.KeyID(), clone.KeyID(), "%s: KeyID()", name)
+		require.Equal(*aead.ks, *clone.ks, "%s: ks", name)
+		require.NotSame(aead.ks, clone.ks, "%s: ks shared", name)
+
+		c := aead.SealKeyed(nil, nonce[:], m, nil)
+		require.Equal(c, clone.SealKeyed(nil, nonce[:], m, nil), "%s: SealKeyed()", name)
+
+		// Wiping either does not affect the other.
+		clone.Wipe()
+		p, err := aead.OpenKeyed(nil, nonce[:], c, nil)
+		require.NoError(err, "%s: OpenKeyed() after clone Wipe()", name)
+		require.Equal(m, p, "%s: OpenKeyed() after clone Wipe()", name)
+
+		clone = aead.Clone()
+		aead.Wipe()
+		p, err = clone.OpenKeyed(nil, nonce[:], c, nil)
+		require.NoError(err, "%s: OpenKeyed() after original Wipe()", name)
+		require.Equal(m, p, "%s: OpenKeyed() after original Wipe()", name)
+
+		require.PanicsWithValue(ErrKeyReset, func() { aead.Clone() }, "%s: Clone() after Wipe()", name)
+	}
+}
+
+// TestOpenTimingUniformity checks that the time taken by Open does not
+// depend on whether (or where) the ciphertext was tampered with.
+//
+// The only early return in Open is on a ciphertext that is shorter than a
+// tag (or too long to be valid), which depends only on the length. Past
+// that, decryption and hashing always run over the entire message, and the
+// tag comparison is constant time. The remaining difference is that a
+// failed Open zeroes the output, which depends only on the length and the
+// (public) outcome.
+//
+// Wall clock timing is far too noisy on shared or loaded machines for this
+// to be part of the default suite, so it only runs if HS1SIV_TIMING_TESTS
+// is set. ctaudit_test.go statically checks for secret dependent indexing
+// and runs as part of the default suite.
+func TestOpenTimingUniformity(t *testing.T) {
+	if os.Getenv("HS1SIV_TIMING_TESTS") == "" {
+		t.Skip("skipping timing test, set HS1SIV_TIMING_TESTS to run")
+	}
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+	require := require.New(t)
+
+	const (
+		msgSize   = 1024
+		batchSize = 64
+		nBatches  = 200
+		tolerance = 0.15
+	)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := make([]byte, msgSize)
+	_, _ = rand.Read(m)
+	valid := aead.Seal(nil, nonce[:], m, nil)
+	cases := map[string][]byte{"valid": valid}
+	for name, off := range map[string]int{
+		"tampered first byte": 0,
+		"tampered last byte":  msgSize - 1,
+		"tampered first tag":  msgSize,
+		"tampered last tag":   msgSize + TagSize - 1,
+	} {
+		c := append([]byte{}, valid...)
+		c[off] ^= 0x80
+		cases[name] = c
+	}
+
+	dst := make([]byte, 0, msgSize)
+	samples := make(map[string][]time.Duration)
+	for i := 0; i < nBatches; i++ {
+		// Interleave the cases, so that drift (frequency scaling, other
+		// load) affects all of them equally.
+		for name, c := range cases {
+			start := time.Now()
+			for j := 0; j < batchSize; j++ {
+				_, _ = aead.Open(dst, nonce[:], c, nil)
+			}
+			samples[name] = append(samples[name], time.Since(start))
+		}
+	}
+
+	// Compare the trimmed means (discarding the slowest and fastest 10% of
+	// batches), which are far less sensitive to scheduling noise.
+	trimmedMean := func(d []time.Duration) float64 {
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		d = d[len(d)/10 : len(d)-len(d)/10]
+		var sum float64
+		for _, v := range d {
+			sum += float64(v)
+		}
+		return sum / float64(len(d))
+	}
+	baseline := trimmedMean(samples["valid"])
+	for name, d := range samples {
+		mean := trimmedMean(d)
+		delta := math.Abs(mean-baseline) / baseline
+		t.Logf("%s: %.0f ns/op (%+.2f%%)", name, mean/batchSize, 100*(mean-baseline)/baseline)
+		require.True(delta < tolerance, "Open timing: %s differs from valid by %.2f%%", name, 100*delta)
+	}
+}
+
 func BenchmarkHS1SIV(b *testing.B) {
 	benchSizes := []int{8, 32, 64, 576, 1536, 4096, 1024768}
 
@@ -87,7 +1210,17 @@ func BenchmarkHS1SIV(b *testing.B) {
 		bn := "HS1-SIV"
 		sn := fmt.Sprintf("_%d", sz)
 		b.Run(bn+"Encrypt"+sn, func(b *testing.B) { doBenchmarkAEADEncrypt(b, sz) })
-		b.Run(bn+"Decrypt"+sn, func(b *testing.B) { doBenchmarkAEADDecrypt(b, sz) })
+		b.Run(bn+"Decrypt"+sn, func(b *testing.B) { doBenchmarkAEADDecrypt(b, sz, false) })
+	}
+}
+
+func BenchmarkHS1SIVOpenFused(b *testing.B) {
+	benchSizes := []int{4096, 1048576}
+
+	for _, sz := range benchSizes {
+		sn := fmt.Sprintf("_%d", sz)
+		b.Run("TwoPass"+sn, func(b *testing.B) { doBenchmarkAEADDecrypt(b, sz, false) })
+		b.Run("Fused"+sn, func(b *testing.B) { doBenchmarkAEADDecrypt(b, sz, true) })
 	}
 }
 
@@ -113,7 +1246,7 @@ func doBenchmarkAEADEncrypt(b *testing.B, sz int) {
 	}
 }
 
-func doBenchmarkAEADDecrypt(b *testing.B, sz int) {
+func doBenchmarkAEADDecrypt(b *testing.B, sz int, fused bool) {
 	b.StopTimer()
 	b.SetBytes(int64(sz))
 
@@ -130,7 +1263,11 @@ func doBenchmarkAEADDecrypt(b *testing.B, sz int) {
 		d = d[:0]
 
 		var err error
-		d, err = aead.Open(d, nonce, c, nil)
+		if fused {
+			d, err = aead.OpenFused(d, nonce, c, nil)
+		} else {
+			d, err = aead.Open(d, nonce, c, nil)
+		}
 		if err != nil {
 			b.Fatalf("Open failed")
 		}
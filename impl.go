@@ -0,0 +1,77 @@
+// impl.go - Implementation selection
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// ImplementationXCrypto is the name of the implementation that uses
+	// golang.org/x/crypto/chacha20, which may use assembly depending on
+	// the platform. This is the default, unless built with the
+	// hs1siv_purego tag.
+	ImplementationXCrypto = "XCrypto"
+
+	// ImplementationReference is the name of the pure Go implementation.
+	ImplementationReference = "Reference"
+)
+
+// ErrUnsupportedImplementation is the error returned when an implementation
+// is not available.
+var ErrUnsupportedImplementation = errors.New("hs1siv: implementation not available")
+
+var referenceSelected uint32
+
+func isReferenceSelected() bool {
+	return atomic.LoadUint32(&referenceSelected) != 0
+}
+
+// Implementations returns the names of the implementations available in
+// this build, in order of preference.
+//
+// The HS1 hash is always portable Go, so the implementations only differ in
+// how ChaCha20 is done. The output is identical regardless.
+func Implementations() []string {
+	return append([]string{}, availableImplementations...)
+}
+
+// ImplementationName returns the name of the currently selected
+// implementation, which is suitable for logging. It is safe to call
+// concurrently with SetImplementation.
+func ImplementationName() string {
+	if isReferenceSelected() {
+		return ImplementationReference
+	}
+	return availableImplementations[0]
+}
+
+// SetImplementation selects the named implementation for all subsequent
+// operations, or returns ErrUnsupportedImplementation if it is not
+// available in this build. It is safe to call concurrently with other
+// operations, though ones that are already in progress (including readers
+// and writers) will continue with the implementation that they started
+// with.
+func SetImplementation(name string) error {
+	var found bool
+	for _, v := range availableImplementations {
+		found = found || v == name
+	}
+	if !found {
+		return fmt.Errorf("%w: %q", ErrUnsupportedImplementation, name)
+	}
+
+	var v uint32
+	if name == ImplementationReference {
+		v = 1
+	}
+	atomic.StoreUint32(&referenceSelected, v)
+	return nil
+}
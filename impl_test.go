@@ -0,0 +1,92 @@
+// impl_test.go - Implementation selection tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImplementations(t *testing.T) {
+	require := require.New(t)
+
+	impls := Implementations()
+	require.NotEmpty(impls, "Implementations()")
+	require.Contains(impls, ImplementationReference, "Implementations()")
+	require.Equal(impls[0], ImplementationName(), "ImplementationName(): default")
+	defer func() {
+		require.NoError(SetImplementation(impls[0]), "SetImplementation(default)")
+	}()
+
+	Implementations()[0] = "mutated"
+	require.NotContains(Implementations(), "mutated", "Implementations() returns a copy")
+
+	err := SetImplementation("AVX2")
+	require.True(errors.Is(err, ErrUnsupportedImplementation), "SetImplementation(AVX2): %v", err)
+	require.Equal(impls[0], ImplementationName(), "ImplementationName(): after failed selection")
+
+	w, h, k, n := katInputs()
+	aead := New(k[:])
+	for _, impl := range Implementations() {
+		require.NoError(SetImplementation(impl), "SetImplementation(%s)", impl)
+		require.Equal(impl, ImplementationName(), "ImplementationName()")
+
+		// The final KAT vector.
+		c := aead.Seal(nil, n[:], w[:len(w)-1], h[:len(h)-1])
+		require.Equal(kaths1siv[len(kaths1siv)-len(c):], c, "Seal(): %s", impl)
+
+		// The streaming code uses newChaCha20 rather than chacha20.
+		var buf bytes.Buffer
+		r, err := aead.SealReader(n[:], w[:len(w)-1], h[:len(h)-1])
+		require.NoError(err, "SealReader(): %s", impl)
+		_, err = io.Copy(&buf, r)
+		require.NoError(err, "SealReader(): %s", impl)
+		require.Equal(c, buf.Bytes(), "SealReader(): %s", impl)
+	}
+}
+
+func TestSetImplementationConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	impls := Implementations()
+	defer func() {
+		require.NoError(SetImplementation(impls[0]), "SetImplementation(default)")
+	}()
+
+	w, h, k, n := katInputs()
+	aead := New(k[:])
+	expected := aead.Seal(nil, n[:], w[:], h[:])
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				results[i] = aead.Seal(results[i][:0], n[:], w[:], h[:])
+				if !bytes.Equal(expected, results[i]) {
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 64; i++ {
+		_ = SetImplementation(impls[i%len(impls)])
+	}
+	wg.Wait()
+
+	for i, c := range results {
+		require.Equal(expected, c, "Seal(): goroutine %d", i)
+	}
+}
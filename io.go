@@ -0,0 +1,456 @@
+// io.go - HS1-SIV io helpers
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"os"
+)
+
+// ioChunkSize is the size of the chunks that the io helpers process data
+// in, and MUST be a multiple of hs1NHLen.
+const ioChunkSize = 512 * hs1NHLen
+
+var errSealWriterClosed = errors.New("hs1siv: SealWriter is closed")
+
+// ErrTruncatedStream is the error returned when a stream ends before the
+// declared length of the sealed message has been read.
+var ErrTruncatedStream = errors.New("hs1siv: truncated stream")
+
+// SealReader returns an io.Reader that yields the same output as Seal,
+// encrypting the plaintext lazily as the reader is consumed.
+//
+// The SIV still requires a full pass over the plaintext before anything can
+// be returned, however the ciphertext is never buffered. The plaintext MUST
+// NOT be modified until the reader has been fully consumed.
+//
+// ErrInvalidPlaintextSize is returned if the plaintext is larger than
+// MaxPlaintextSize.
+func (ae *AEAD) SealReader(nonce, plaintext, additionalData []byte) (io.Reader, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		return nil, err
+	}
+
+	// The reader outlives the read lock, so it gets a copy of the key
+	// schedule.
+	ae.rLock()
+	var ks keySchedule
+	cached, err := ae.schedule()
+	if err == nil {
+		ks = *cached
+	}
+	ctx := ae.newCtx(&ks)
+	ae.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &sealReader{
+		m:   plaintext,
+		siv: make([]byte, ks.p.SIVLen),
+	}
+	t := statsStart()
+	ctx.sivSetup(len(additionalData), len(plaintext))
+	ctx.sivHashAD(additionalData)
+	ctx.sivGenerate(plaintext, nonce, r.siv)
+	t = statsHash(t, len(additionalData)+len(plaintext))
+
+	var chachaKey [chacha20KeySize]byte
+	ctx.streamKey(r.siv, chachaKey[:])
+	r.stream = mustNewChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, 1)
+	memwipe(chachaKey[:])
+	statsCipher(t, 0)
+
+	return r, nil
+}
+
+// OpenReader reads a sealed message of exactly sealedLen bytes (as signaled
+// out of band) from r, and decrypts and authenticates it as with Open.
+//
+// If r ends before sealedLen bytes have been read, ErrTruncatedStream is
+// returned without attempting authentication. Trailing data past sealedLen
+// is left unread.
+func (ae *AEAD) OpenReader(nonce []byte, r io.Reader, sealedLen int64, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	tagSize := int64(ae.Overhead())
+	if sealedLen < tagSize || checkPlaintextSize(uint64(sealedLen-tagSize)) != nil {
+		return nil, ErrInvalidCiphertextSize
+	}
+
+	// Grow the buffer as data arrives, rather than trusting sealedLen
+	// with an up front allocation.
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(io.LimitReader(r, sealedLen))
+	if err != nil {
+		return nil, err
+	}
+	if n != sealedLen {
+		return nil, ErrTruncatedStream
+	}
+
+	c := buf.Bytes()
+	return ae.Open(c[:0], nonce, c, additionalData)
+}
+
+// OpenToWriter decrypts and authenticates ciphertext as with Open, and if
+// successful writes the plaintext to w, returning the number of bytes
+// written. If authentication fails, nothing is written and ErrOpen is
+// returned.
+//
+// The ciphertext is decrypted twice in fixed size chunks, once to verify
+// the SIV and once to write the plaintext, so the full plaintext is never
+// held in memory.
+func (ae *AEAD) OpenToWriter(w io.Writer, nonce, ciphertext, additionalData []byte) (int, error) {
+	if len(nonce) != NonceSize {
+		return 0, ErrInvalidNonceSize
+	}
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	if !ae.isValidCiphertextSize(len(ciphertext)) {
+		return 0, ErrOpen
+	}
+	sivLen := ae.p.SIVLen
+	mBytes := len(ciphertext) - sivLen
+	c := ciphertext[:mBytes]
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return 0, err
+	}
+	t := statsStart()
+	d := digest{
+		ctx: ae.newCtx(ks),
+	}
+	d.ctx.sivSetup(len(additionalData), 0)
+	d.ctx.sivHashAD(additionalData)
+	t = statsHash(t, len(additionalData))
+
+	var sivBuf, maybeSIVBuf [hs1SIVLen]byte
+	var chachaKey [chacha20KeySize]byte
+	siv, maybeSIV := sivBuf[:sivLen], maybeSIVBuf[:sivLen]
+	copy(siv, ciphertext[mBytes:])
+	d.ctx.streamKey(siv, chachaKey[:])
+	t = statsCipher(t, 0)
+
+	var buf [ioChunkSize]byte
+	defer func() {
+		memwipe(buf[:])
+		memwipe(chachaKey[:])
+		memwipe(sivBuf[:])
+		memwipe(maybeSIVBuf[:])
+	}()
+
+	// First pass: Decrypt and derive the SIV, discarding the plaintext.
+	stream := mustNewChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, 1)
+	for off := 0; off < mBytes; {
+		n := copy(buf[:], c[off:])
+		stream.XORKeyStream(buf[:n], buf[:n])
+		t = statsCipher(t, n)
+		_, _ = d.Write(buf[:n])
+		t = statsHash(t, n)
+		off += n
+	}
+	d.sivSum(nonce, maybeSIV)
+	t = statsHash(t, 0)
+	if subtle.ConstantTimeCompare(siv, maybeSIV) != 1 {
+		return 0, ErrOpen
+	}
+
+	// Second pass: Decrypt and write the now authenticated plaintext.
+	var written int
+	stream = mustNewChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, 1)
+	for off := 0; off < mBytes; {
+		n := copy(buf[:], c[off:])
+		stream.XORKeyStream(buf[:n], buf[:n])
+		t = statsCipher(t, n)
+		nWritten, err := w.Write(buf[:n])
+		written += nWritten
+		if err != nil {
+			return written, err
+		}
+		off += n
+	}
+
+	return written, nil
+}
+
+type sealReader struct {
+	stream cipher.Stream
+	m      []byte
+	siv    []byte
+	sivOff int
+}
+
+func (r *sealReader) Read(p []byte) (int, error) {
+	var n int
+	if len(r.m) > 0 {
+		n = len(p)
+		if n > len(r.m) {
+			n = len(r.m)
+		}
+		t := statsStart()
+		r.stream.XORKeyStream(p[:n], r.m[:n])
+		statsCipher(t, n)
+		r.m = r.m[n:]
+		p = p[n:]
+	}
+	if len(r.m) == 0 {
+		cpLen := copy(p, r.siv[r.sivOff:])
+		r.sivOff += cpLen
+		n += cpLen
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// SealWriterOptions are the options for a SealWriter.
+type SealWriterOptions struct {
+	// MaxMemBytes is the maximum number of plaintext bytes to buffer in
+	// memory. If more plaintext is written, all of it is spilled to a
+	// temporary file. If 0, the plaintext is always buffered in memory.
+	//
+	// Note that the spilled plaintext is stored on disk unencrypted
+	// until Close.
+	MaxMemBytes int
+
+	// TempDir is the directory in which the temporary file is created. If
+	// empty, the default directory for temporary files is used.
+	TempDir string
+}
+
+// SealWriter is an io.WriteCloser that buffers all of the plaintext written
+// to it, and on Close writes the same output as Seal to the underlying
+// io.Writer.
+//
+// Close MUST be called even if the SealWriter is abandoned, as it is what
+// removes the temporary file holding any spilled plaintext. If a Write
+// fails, the buffered plaintext and the temporary file are discarded
+// immediately, and the error is returned by every subsequent call.
+type SealWriter struct {
+	ae    *AEAD
+	w     io.Writer
+	nonce []byte
+	ad    []byte
+	opts  SealWriterOptions
+
+	buf    []byte
+	f      *os.File
+	err    error
+	closed bool
+}
+
+// NewSealWriter returns a new SealWriter, that will seal all of the
+// plaintext written to it, writing the result to w on Close. opts may be
+// nil, in which case the default options are used.
+func (ae *AEAD) NewSealWriter(w io.Writer, nonce, additionalData []byte, opts *SealWriterOptions) (*SealWriter, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	sw := &SealWriter{
+		ae:    ae,
+		w:     w,
+		nonce: append([]byte{}, nonce...),
+		ad:    append([]byte{}, additionalData...),
+	}
+	if opts != nil {
+		sw.opts = *opts
+	}
+	return sw, nil
+}
+
+// Write buffers p, to be sealed on Close.
+func (sw *SealWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errSealWriterClosed
+	}
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	if sw.f == nil && sw.opts.MaxMemBytes > 0 && len(sw.buf)+len(p) > sw.opts.MaxMemBytes {
+		f, err := os.CreateTemp(sw.opts.TempDir, "hs1siv-")
+		if err != nil {
+			return 0, sw.fail(err)
+		}
+		sw.f = f
+		if _, err = f.Write(sw.buf); err != nil {
+			return 0, sw.fail(err)
+		}
+		memwipe(sw.buf)
+		sw.buf = nil
+	}
+	if sw.f != nil {
+		n, err := sw.f.Write(p)
+		if err != nil {
+			return n, sw.fail(err)
+		}
+		return n, nil
+	}
+
+	sw.buf = append(sw.buf, p...)
+	return len(p), nil
+}
+
+// Close seals the buffered plaintext, and writes the result to the
+// underlying io.Writer, and removes the temporary file if any. It does not
+// close the underlying io.Writer.
+func (sw *SealWriter) Close() error {
+	if sw.closed {
+		return errSealWriterClosed
+	}
+	sw.closed = true
+	if sw.err != nil {
+		return sw.err
+	}
+
+	if sw.f == nil {
+		sw.buf = sw.ae.Seal(sw.buf[:0], sw.nonce, sw.buf, sw.ad)
+		_, err := sw.w.Write(sw.buf)
+		return err
+	}
+
+	defer sw.discard()
+	if _, err := sw.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return sw.ae.SealSeekable(sw.w, sw.f, sw.nonce, sw.ad)
+}
+
+// fail discards the buffered plaintext, and records err to be returned by
+// all further calls.
+func (sw *SealWriter) fail(err error) error {
+	sw.err = err
+	sw.discard()
+	return err
+}
+
+// discard wipes the in-memory plaintext, and closes and removes the
+// temporary file if any.
+func (sw *SealWriter) discard() {
+	memwipe(sw.buf)
+	sw.buf = nil
+	if sw.f != nil {
+		_ = sw.f.Close()
+		_ = os.Remove(sw.f.Name())
+		sw.f = nil
+	}
+}
+
+// SealSeekable seals the plaintext read from src starting at the current
+// offset, writing the same output as Seal to dst, without buffering the
+// plaintext in memory.
+//
+// The SIV is derived by reading src to EOF, after which src is rewound to
+// the starting offset and read again for encryption, so src must not change
+// between the two passes. If an error is returned, a partial ciphertext
+// may have been written to dst, except that ErrInvalidPlaintextSize is
+// returned before reading the plaintext if src is larger than
+// MaxPlaintextSize.
+func (ae *AEAD) SealSeekable(dst io.Writer, src io.ReadSeeker, nonce, additionalData []byte) error {
+	if len(nonce) != NonceSize {
+		return ErrInvalidNonceSize
+	}
+
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if err = checkPlaintextSize(uint64(end - start)); err != nil {
+		return err
+	}
+	if _, err = src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return err
+	}
+	t := statsStart()
+	d := digest{
+		ctx: ae.newCtx(ks),
+	}
+	d.ctx.sivSetup(len(additionalData), 0)
+	d.ctx.sivHashAD(additionalData)
+	statsHash(t, len(additionalData))
+
+	// First pass: Derive the SIV.
+	var buf [ioChunkSize]byte
+	for {
+		n, err := src.Read(buf[:])
+		t = statsStart()
+		_, _ = d.Write(buf[:n])
+		statsHash(t, n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = checkPlaintextSize(d.mBytes); err != nil {
+		return err
+	}
+	var sivBuf [hs1SIVLen]byte
+	siv := sivBuf[:ks.p.SIVLen]
+	t = statsStart()
+	d.sivSum(nonce, siv)
+	t = statsHash(t, 0)
+
+	// Second pass: Encrypt.
+	if _, err = src.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	var chachaKey [chacha20KeySize]byte
+	d.ctx.streamKey(siv, chachaKey[:])
+	stream := mustNewChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, 1)
+	memwipe(chachaKey[:])
+	statsCipher(t, 0)
+	for remaining := d.mBytes; remaining > 0; {
+		n := uint64(len(buf))
+		if n > remaining {
+			n = remaining
+		}
+		if _, err = io.ReadFull(src, buf[:n]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		t = statsStart()
+		stream.XORKeyStream(buf[:n], buf[:n])
+		statsCipher(t, int(n))
+		if _, err = dst.Write(buf[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+
+	_, err = dst.Write(siv)
+	return err
+}
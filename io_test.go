@@ -0,0 +1,369 @@
+// io_test.go - HS1-SIV io helper tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealReader(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("reader ad")
+	for _, sz := range []int{0, 1, 63, 64, 65, 1000, 4096 + 17} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		expected := aead.Seal(nil, nonce[:], m, ad)
+
+		r, err := aead.SealReader(nonce[:], m, ad)
+		require.NoError(err, "SealReader(): %d", sz)
+		c, err := io.ReadAll(r)
+		require.NoError(err, "ReadAll(): %d", sz)
+		require.Equal(expected, c, "SealReader(): %d", sz)
+
+		// Small reads that straddle the ciphertext and the tag.
+		r, _ = aead.SealReader(nonce[:], m, ad)
+		c, err = io.ReadAll(iotest.OneByteReader(r))
+		require.NoError(err, "ReadAll(OneByteReader): %d", sz)
+		require.Equal(expected, c, "SealReader(OneByteReader): %d", sz)
+
+		r, _ = aead.SealReader(nonce[:], m, ad)
+		require.NoError(iotest.TestReader(r, expected), "iotest.TestReader(): %d", sz)
+	}
+
+	_, err := aead.SealReader(nonce[1:], nil, nil)
+	require.Equal(ErrInvalidNonceSize, err, "SealReader(bad nonce)")
+}
+
+func TestOpenReader(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("reader ad")
+	for _, sz := range []int{0, 1, 64, 4096 + 17} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		c := aead.Seal(nil, nonce[:], m, ad)
+		sealedLen := int64(len(c))
+
+		r, _ := aead.SealReader(nonce[:], m, ad)
+		d, err := aead.OpenReader(nonce[:], iotest.HalfReader(r), sealedLen, ad)
+		require.NoError(err, "OpenReader(): %d", sz)
+		require.True(bytes.Equal(m, d), "OpenReader(): %d", sz)
+
+		// Trailing data is not consumed.
+		rd := bytes.NewReader(append(append([]byte{}, c...), "trailer"...))
+		_, err = aead.OpenReader(nonce[:], rd, sealedLen, ad)
+		require.NoError(err, "OpenReader(trailer): %d", sz)
+		require.Equal(len("trailer"), rd.Len(), "OpenReader(trailer): %d", sz)
+
+		// A stream cut short is rejected before authentication, even
+		// when the cut is on a block boundary.
+		for _, cut := range []int{1, TagSize, len(c)} {
+			_, err = aead.OpenReader(nonce[:], bytes.NewReader(c[:len(c)-cut]), sealedLen, ad)
+			require.Equal(ErrTruncatedStream, err, "OpenReader(truncated %d): %d", cut, sz)
+		}
+
+		// Whereas a corrupted stream of the right length fails to
+		// authenticate.
+		bad := append([]byte{}, c...)
+		bad[0] ^= 0x01
+		_, err = aead.OpenReader(nonce[:], bytes.NewReader(bad), sealedLen, ad)
+		require.Equal(ErrOpen, err, "OpenReader(corrupted): %d", sz)
+	}
+
+	_, err := aead.OpenReader(nonce[:], iotest.ErrReader(iotest.ErrTimeout), TagSize, nil)
+	require.Equal(iotest.ErrTimeout, err, "OpenReader(broken reader)")
+	_, err = aead.OpenReader(nonce[:], bytes.NewReader(nil), TagSize-1, nil)
+	require.Equal(ErrInvalidCiphertextSize, err, "OpenReader(short length)")
+	_, err = aead.OpenReader(nonce[1:], bytes.NewReader(nil), TagSize, nil)
+	require.Equal(ErrInvalidNonceSize, err, "OpenReader(bad nonce)")
+}
+
+type countingWriter struct {
+	n int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += len(p)
+	return len(p), nil
+}
+
+func TestOpenToWriter(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	ad := []byte("writer ad")
+	for _, sz := range []int{0, 1, 63, 64, 65, ioChunkSize, 2*ioChunkSize + 17} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		c := aead.Seal(nil, nonce[:], m, ad)
+
+		var out bytes.Buffer
+		n, err := aead.OpenToWriter(&out, nonce[:], c, ad)
+		require.NoError(err, "OpenToWriter(): %d", sz)
+		require.Equal(sz, n, "OpenToWriter(): %d", sz)
+		require.True(bytes.Equal(m, out.Bytes()), "OpenToWriter(): %d", sz)
+
+		// Nothing is written if authentication fails, including when
+		// the corruption is in the last chunk.
+		for _, off := range []int{0, len(c) - TagSize - 1, len(c) - 1} {
+			if off < 0 {
+				continue
+			}
+			bad := append([]byte{}, c...)
+			bad[off] ^= 0x01
+			var w countingWriter
+			n, err = aead.OpenToWriter(&w, nonce[:], bad, ad)
+			require.Equal(ErrOpen, err, "OpenToWriter(bad %d): %d", off, sz)
+			require.Zero(n, "OpenToWriter(bad %d): %d", off, sz)
+			require.Zero(w.n, "OpenToWriter(bad %d): %d: written", off, sz)
+		}
+	}
+
+	_, err := aead.OpenToWriter(io.Discard, nonce[:], make([]byte, TagSize-1), nil)
+	require.Equal(ErrOpen, err, "OpenToWriter(short)")
+	_, err = aead.OpenToWriter(io.Discard, nonce[1:], make([]byte, TagSize), nil)
+	require.Equal(ErrInvalidNonceSize, err, "OpenToWriter(bad nonce)")
+}
+
+func TestSealWriter(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	const maxMem = 1000
+	tmpDir := t.TempDir()
+	ad := []byte("writer ad")
+	for _, sz := range []int{0, 1, maxMem, maxMem + 1, 3*ioChunkSize + 17} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		expected := aead.Seal(nil, nonce[:], m, ad)
+
+		for _, opts := range []*SealWriterOptions{
+			nil,
+			{MaxMemBytes: maxMem, TempDir: tmpDir},
+		} {
+			var out bytes.Buffer
+			sw, err := aead.NewSealWriter(&out, nonce[:], ad, opts)
+			require.NoError(err, "NewSealWriter(): %d", sz)
+
+			// Write in uneven pieces.
+			for off := 0; off < len(m); {
+				n := 1 + (off*31)%777
+				if off+n > len(m) {
+					n = len(m) - off
+				}
+				wrLen, err := sw.Write(m[off : off+n])
+				require.NoError(err, "Write(): %d", sz)
+				require.Equal(n, wrLen, "Write(): %d", sz)
+				off += n
+			}
+			spilled := sw.f != nil
+			if opts != nil && sz > maxMem {
+				require.True(spilled, "spilled: %d", sz)
+			} else {
+				require.False(spilled, "spilled: %d", sz)
+			}
+
+			require.NoError(sw.Close(), "Close(): %d", sz)
+			require.Equal(expected, out.Bytes(), "SealWriter(): %d", sz)
+
+			_, err = sw.Write([]byte{0})
+			require.Error(err, "Write(closed)")
+			require.Error(sw.Close(), "Close(closed)")
+		}
+	}
+
+	// The temporary files must be cleaned up.
+	entries, err := os.ReadDir(tmpDir)
+	require.NoError(err, "ReadDir()")
+	require.Len(entries, 0, "temporary files")
+}
+
+func TestSealWriterCleanup(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	const maxMem = 100
+	m := make([]byte, 2*maxMem)
+	_, _ = rand.Read(m)
+	opts := &SealWriterOptions{MaxMemBytes: maxMem, TempDir: t.TempDir()}
+	requireNoSpill := func(name string) {
+		_, err := os.Stat(name)
+		require.True(os.IsNotExist(err), "Stat(%s): %v", name, err)
+		entries, err := os.ReadDir(opts.TempDir)
+		require.NoError(err, "ReadDir()")
+		require.Len(entries, 0, "temporary files")
+	}
+
+	// Close removes the file.
+	sw, err := aead.NewSealWriter(io.Discard, nonce[:], nil, opts)
+	require.NoError(err, "NewSealWriter()")
+	_, err = sw.Write(m)
+	require.NoError(err, "Write()")
+	require.NotNil(sw.f, "spilled")
+	name := sw.f.Name()
+	require.NoError(sw.Close(), "Close()")
+	requireNoSpill(name)
+
+	// A failed write to the file removes it without waiting for Close.
+	sw, err = aead.NewSealWriter(io.Discard, nonce[:], nil, opts)
+	require.NoError(err, "NewSealWriter()")
+	_, err = sw.Write(m)
+	require.NoError(err, "Write()")
+	name = sw.f.Name()
+	require.NoError(sw.f.Close(), "File.Close()")
+	_, err = sw.Write(m)
+	require.Error(err, "Write(failed)")
+	requireNoSpill(name)
+	_, err2 := sw.Write(m)
+	require.Equal(err, err2, "Write(after failure)")
+	require.Equal(err, sw.Close(), "Close(after failure)")
+
+	// A failure to create the file wipes the in-memory plaintext.
+	sw, err = aead.NewSealWriter(io.Discard, nonce[:], nil, &SealWriterOptions{
+		MaxMemBytes: maxMem,
+		TempDir:     filepath.Join(opts.TempDir, "missing"),
+	})
+	require.NoError(err, "NewSealWriter()")
+	_, err = sw.Write(m[:maxMem])
+	require.NoError(err, "Write()")
+	buf := sw.buf
+	_, err = sw.Write(m[maxMem:])
+	require.Error(err, "Write(CreateTemp failed)")
+	require.Equal(make([]byte, maxMem), buf, "buffered plaintext")
+	require.Nil(sw.buf, "buffered plaintext")
+}
+
+type badSeeker struct {
+	io.Reader
+}
+
+func (s *badSeeker) Seek(int64, int) (int64, error) {
+	return 0, errors.New("seek failed")
+}
+
+func TestSealSeekable(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	f, err := os.CreateTemp(t.TempDir(), "seekable-")
+	require.NoError(err, "CreateTemp()")
+	defer f.Close()
+
+	ad := []byte("seekable ad")
+	for _, sz := range []int{0, 1, ioChunkSize, 3*ioChunkSize + 17} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		expected := aead.Seal(nil, nonce[:], m, ad)
+
+		// Sealing starts at the current offset, not the start of the file.
+		require.NoError(f.Truncate(0), "Truncate(): %d", sz)
+		_, err = f.WriteAt(append([]byte("prefix"), m...), 0)
+		require.NoError(err, "WriteAt(): %d", sz)
+		_, err = f.Seek(int64(len("prefix")), io.SeekStart)
+		require.NoError(err, "Seek(): %d", sz)
+
+		var out bytes.Buffer
+		require.NoError(aead.SealSeekable(&out, f, nonce[:], ad), "SealSeekable(): %d", sz)
+		require.Equal(expected, out.Bytes(), "SealSeekable(): %d", sz)
+
+		out.Reset()
+		require.NoError(aead.SealSeekable(&out, bytes.NewReader(m), nonce[:], ad), "SealSeekable(bytes.Reader): %d", sz)
+		require.Equal(expected, out.Bytes(), "SealSeekable(bytes.Reader): %d", sz)
+	}
+
+	err = aead.SealSeekable(io.Discard, &badSeeker{bytes.NewReader(nil)}, nonce[:], nil)
+	require.EqualError(err, "seek failed", "SealSeekable(bad seeker)")
+	err = aead.SealSeekable(io.Discard, bytes.NewReader(nil), nonce[1:], nil)
+	require.Equal(ErrInvalidNonceSize, err, "SealSeekable(bad nonce)")
+}
+
+func TestIOSizeLimit(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	const limit = 2*hs1NHLen + 1
+	m := make([]byte, limit+1)
+	_, _ = rand.Read(m)
+	oversized := aead.Seal(nil, nonce[:], m, nil)
+	setMaxPlaintextSize(t, limit)
+
+	r, err := aead.SealReader(nonce[:], m, nil)
+	require.Equal(ErrInvalidPlaintextSize, err, "SealReader(oversized)")
+	require.Nil(r, "SealReader(oversized)")
+	r, err = aead.SealReader(nonce[:], m[:limit], nil)
+	require.NoError(err, "SealReader(limit)")
+	c, err := io.ReadAll(r)
+	require.NoError(err, "SealReader(limit): ReadAll()")
+	require.Equal(aead.Seal(nil, nonce[:], m[:limit], nil), c, "SealReader(limit)")
+
+	var b bytes.Buffer
+	n, err := aead.OpenToWriter(&b, nonce[:], oversized, nil)
+	require.Equal(ErrOpen, err, "OpenToWriter(oversized)")
+	require.Zero(n, "OpenToWriter(oversized)")
+	require.Zero(b.Len(), "OpenToWriter(oversized): output")
+	n, err = aead.OpenToWriter(&b, nonce[:], c, nil)
+	require.NoError(err, "OpenToWriter(limit)")
+	require.Equal(limit, n, "OpenToWriter(limit)")
+
+	// The size is checked before anything is read or written.
+	b.Reset()
+	src := bytes.NewReader(m)
+	err = aead.SealSeekable(&b, src, nonce[:], nil)
+	require.Equal(ErrInvalidPlaintextSize, err, "SealSeekable(oversized)")
+	require.Zero(b.Len(), "SealSeekable(oversized): output")
+	_, _ = src.Seek(1, io.SeekStart)
+	require.NoError(aead.SealSeekable(&b, src, nonce[:], nil), "SealSeekable(limit)")
+	require.Equal(aead.Seal(nil, nonce[:], m[1:], nil), b.Bytes(), "SealSeekable(limit)")
+}
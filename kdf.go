@@ -0,0 +1,65 @@
+// kdf.go - HS1-SIV subkey derivation
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// KDF purposes, used for domain separation between derived keys. Zero is
+// reserved for the HS1-SIV key schedule itself.
+const (
+	kdfPurposeTyped byte = 1 + iota
+	kdfPurposeUniversalHash
+	kdfPurposeCommitment
+	kdfPurposeADNonce
+	kdfPurposeKeyFromSeed
+	kdfPurposeTranscript
+)
+
+// KeyFromSeed deterministically expands an arbitrary length seed into a key.
+//
+// WARNING: This is intended for reproducible test fixtures and simulations,
+// and MUST NOT be used to generate production keys, which should be read
+// from crypto/rand. The output is only as unpredictable as the seed.
+func KeyFromSeed(seed []byte) [KeySize]byte {
+	var fixedKey, key [KeySize]byte
+	if err := deriveKey(fixedKey[:], kdfPurposeKeyFromSeed, seed, &key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// deriveKey derives a KeySize byte subkey from userKey, bound to purpose and
+// info.
+//
+// The ChaCha20 key setup nonce is used with the purpose in the final byte
+// (always zero for the HS1-SIV key schedule) to derive an intermediate
+// key, which is then used to compute the HS1 digest of info.
+func deriveKey(userKey []byte, purpose byte, info []byte, subKey *[KeySize]byte) error {
+	kdfNonce := paramsHi.settings(len(userKey))
+	kdfNonce[chacha20NonceSize-1] = purpose
+
+	var expandedKey [chacha20KeySize]byte
+	var kdfKey [KeySize]byte
+	err := chacha20(expandUserKey(userKey, &expandedKey), kdfNonce[:], kdfKey[:], kdfKey[:], 0)
+	memwipe(expandedKey[:])
+	if err != nil {
+		return err
+	}
+
+	var ks keySchedule
+	err = ks.setup(kdfKey[:], &paramsHi)
+	memwipe(kdfKey[:])
+	if err != nil {
+		return err
+	}
+	d := digest{
+		ctx: aeadCtx{keySchedule: &ks},
+	}
+	d.Reset()
+	_, _ = d.Write(info)
+	d.Sum(subKey[:0])
+	return nil
+}
@@ -0,0 +1,74 @@
+// kdf_test.go - HS1-SIV subkey derivation tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTyped(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	labels := []string{"request", "response", "event"}
+	aeads := make([]*AEAD, 0, len(labels))
+	for _, l := range labels {
+		aeads = append(aeads, NewTyped(key[:], []byte(l)))
+	}
+
+	m := []byte("typed message")
+	ad := []byte("typed ad")
+	for i, aead := range aeads {
+		c := aead.Seal(nil, nonce[:], m, ad)
+
+		d, err := aead.Open(nil, nonce[:], c, ad)
+		require.NoError(err, "Open(%s)", labels[i])
+		require.Equal(m, d, "Open(%s)", labels[i])
+
+		// The same label derives the same subkey.
+		d, err = NewTyped(key[:], []byte(labels[i])).Open(nil, nonce[:], c, ad)
+		require.NoError(err, "Open(%s, fresh)", labels[i])
+		require.Equal(m, d, "Open(%s, fresh)", labels[i])
+
+		// Other labels, and the untyped key must not open.
+		for j, other := range aeads {
+			if i == j {
+				continue
+			}
+			_, err = other.Open(nil, nonce[:], c, ad)
+			require.Equal(ErrOpen, err, "Open(%s) with %s", labels[i], labels[j])
+		}
+		_, err = New(key[:]).Open(nil, nonce[:], c, ad)
+		require.Equal(ErrOpen, err, "Open(%s) with untyped", labels[i])
+	}
+
+	// The empty label is still distinct from the untyped key.
+	c := New(key[:]).Seal(nil, nonce[:], m, ad)
+	_, err := NewTyped(key[:], nil).Open(nil, nonce[:], c, ad)
+	require.Equal(ErrOpen, err, "Open(untyped) with empty label")
+}
+
+func TestKeyFromSeed(t *testing.T) {
+	require := require.New(t)
+
+	k1 := KeyFromSeed([]byte("fixture 1"))
+	require.Equal(k1, KeyFromSeed([]byte("fixture 1")), "KeyFromSeed(): deterministic")
+	require.NotEqual(k1, KeyFromSeed([]byte("fixture 2")), "KeyFromSeed(): distinct seeds")
+	require.NotEqual(k1, KeyFromSeed(nil), "KeyFromSeed(nil)")
+	require.NotEqual([KeySize]byte{}, KeyFromSeed(nil), "KeyFromSeed(nil)")
+
+	long := make([]byte, 1000)
+	require.NotEqual(KeyFromSeed(long), KeyFromSeed(long[:999]), "KeyFromSeed(): length")
+}
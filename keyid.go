@@ -0,0 +1,43 @@
+// keyid.go - HS1-SIV key identifiers
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "encoding/binary"
+
+const keyIDSize = 4
+
+// NewWithKeyID returns a new keyed HS1-SIV instance, tagged with the
+// (non-secret) key identifier id.
+func NewWithKeyID(key []byte, id uint32) *AEAD {
+	ae := New(key)
+	ae.keyID = id
+	return ae
+}
+
+// KeyID returns the key identifier of the instance, or 0 if none was set.
+func (ae *AEAD) KeyID() uint32 {
+	return ae.keyID
+}
+
+// SealKeyed is identical to Seal, except that the key identifier is also
+// authenticated, so that a ciphertext will only open under an instance with
+// both the same key and the same key identifier.
+func (ae *AEAD) SealKeyed(dst, nonce, plaintext, additionalData []byte) []byte {
+	return ae.Seal(dst, nonce, plaintext, ae.keyedAD(additionalData))
+}
+
+// OpenKeyed opens a ciphertext produced by SealKeyed.
+func (ae *AEAD) OpenKeyed(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	return ae.Open(dst, nonce, ciphertext, ae.keyedAD(additionalData))
+}
+
+func (ae *AEAD) keyedAD(additionalData []byte) []byte {
+	ad := make([]byte, keyIDSize, keyIDSize+len(additionalData))
+	binary.LittleEndian.PutUint32(ad, ae.keyID)
+	return append(ad, additionalData...)
+}
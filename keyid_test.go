@@ -0,0 +1,47 @@
+// keyid_test.go - HS1-SIV key identifier tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyID(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	require.EqualValues(0, New(key[:]).KeyID(), "New().KeyID()")
+
+	aead := NewWithKeyID(key[:], 0x1337)
+	require.EqualValues(0x1337, aead.KeyID(), "KeyID()")
+
+	m, ad := []byte("keyed message"), []byte("keyed ad")
+
+	// Plain Seal/Open ignore the key identifier.
+	c := aead.Seal(nil, nonce[:], m, ad)
+	d, err := NewWithKeyID(key[:], 7).Open(nil, nonce[:], c, ad)
+	require.NoError(err, "Open(other id)")
+	require.Equal(m, d, "Open(other id)")
+
+	c = aead.SealKeyed(nil, nonce[:], m, ad)
+	d, err = aead.OpenKeyed(nil, nonce[:], c, ad)
+	require.NoError(err, "OpenKeyed()")
+	require.Equal(m, d, "OpenKeyed()")
+
+	_, err = NewWithKeyID(key[:], 7).OpenKeyed(nil, nonce[:], c, ad)
+	require.Equal(ErrOpen, err, "OpenKeyed(other id)")
+	_, err = aead.Open(nil, nonce[:], c, ad)
+	require.Equal(ErrOpen, err, "Open(keyed)")
+}
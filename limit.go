@@ -0,0 +1,34 @@
+// limit.go - HS1-SIV output size limits
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "errors"
+
+// ErrOutputTooLarge is the error returned when sealing would produce more
+// output than the caller allows.
+var ErrOutputTooLarge = errors.New("hs1siv: output too large")
+
+// SealLimited encrypts and authenticates plaintext as with Seal, unless the
+// sealed output (excluding dst) would exceed maxOutput bytes, in which case
+// ErrOutputTooLarge is returned before anything is allocated or computed.
+//
+// This is intended for sealing untrusted input at an API boundary. Unlike
+// Seal, invalid nonces and plaintexts that are too large to ever be sealed
+// are reported as errors rather than panics.
+func (ae *AEAD) SealLimited(dst, nonce, plaintext, additionalData []byte, maxOutput int) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	if uint64(len(plaintext)) > MaxPlaintextSize {
+		return nil, ErrInvalidPlaintextSize
+	}
+	if tagSize := ae.Overhead(); maxOutput < tagSize || len(plaintext) > maxOutput-tagSize {
+		return nil, ErrOutputTooLarge
+	}
+	return ae.Seal(dst, nonce, plaintext, additionalData), nil
+}
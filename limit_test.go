@@ -0,0 +1,56 @@
+// limit_test.go - HS1-SIV output size limit tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealLimited(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := make([]byte, 100)
+	_, _ = rand.Read(m)
+	expected := aead.Seal([]byte("dst"), nonce[:], m, nil)
+
+	for _, maxOutput := range []int{len(m) + TagSize, len(m) + TagSize + 1, math.MaxInt} {
+		c, err := aead.SealLimited([]byte("dst"), nonce[:], m, nil, maxOutput)
+		require.NoError(err, "SealLimited(): %d", maxOutput)
+		require.Equal(expected, c, "SealLimited(): %d", maxOutput)
+	}
+
+	for _, maxOutput := range []int{len(m) + TagSize - 1, TagSize, TagSize - 1, 0, -1, math.MinInt} {
+		dst := []byte("dst")
+		c, err := aead.SealLimited(dst, nonce[:], m, nil, maxOutput)
+		require.Equal(ErrOutputTooLarge, err, "SealLimited(): %d", maxOutput)
+		require.Nil(c, "SealLimited(): %d", maxOutput)
+	}
+
+	c, err := aead.SealLimited(nil, nonce[:], nil, nil, TagSize)
+	require.NoError(err, "SealLimited(empty)")
+	require.Len(c, TagSize, "SealLimited(empty)")
+
+	// Oversized limits do not cause work to be done up front.
+	allocs := testing.AllocsPerRun(10, func() {
+		_, _ = aead.SealLimited(nil, nonce[:], m, nil, 1)
+	})
+	require.Zero(allocs, "SealLimited(too large) allocations")
+
+	_, err = aead.SealLimited(nil, nonce[1:], m, nil, math.MaxInt)
+	require.Equal(ErrInvalidNonceSize, err, "SealLimited(bad nonce)")
+}
@@ -0,0 +1,26 @@
+// memwipe.go - Sensitive memory scrubbing
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "runtime"
+
+// memwipe overwrites b with zeros, and is used to scrub key material and
+// other sensitive intermediaries before they go out of scope.
+//
+// The stores are to memory that is typically dead immediately afterwards,
+// which is exactly what dead store elimination removes. The gc compiler
+// does not currently do so, but memwipe is kept out of line, and b is kept
+// alive past the stores so that they remain observable regardless.
+//
+//go:noinline
+func memwipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
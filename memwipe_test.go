@@ -0,0 +1,40 @@
+// memwipe_test.go - Sensitive memory scrubbing tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemwipe(t *testing.T) {
+	require := require.New(t)
+
+	var buf [maxStateSize + 1]byte
+	for _, sz := range []int{0, 1, chacha20KeySize, len(buf)} {
+		_, _ = rand.Read(buf[:])
+		buf[0] |= 1 // Ensure that the first byte is never already zero.
+		memwipe(buf[:sz])
+		require.Equal(make([]byte, sz), buf[:sz], "memwipe(): %d", sz)
+		if sz < len(buf) {
+			require.NotEqual(make([]byte, len(buf)-sz), buf[sz:], "memwipe(): %d past end", sz)
+		}
+	}
+	memwipe(nil)
+
+	// Scrubbing the stack does not allocate, even though the slice is
+	// passed to a function that is not inlined.
+	allocs := testing.AllocsPerRun(100, func() {
+		var key [chacha20KeySize]byte
+		key[0] = 0x23
+		memwipe(key[:])
+	})
+	require.Zero(allocs, "memwipe(): allocs")
+}
@@ -0,0 +1,186 @@
+// nonce.go - HS1-SIV nonce helpers
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrNonceSourceExhausted is the error returned when a NonceSource can not
+// produce any more unique nonces.
+var ErrNonceSourceExhausted = errors.New("hs1siv: nonce source exhausted")
+
+// NonceSource is a source of nonces, such as a hardware monotonic counter.
+//
+// Implementations MUST be safe for concurrent use, and SHOULD never return
+// the same nonce twice for the lifetime of the key.
+type NonceSource interface {
+	// Next returns the next nonce.
+	Next() ([NonceSize]byte, error)
+}
+
+// NewCounterNonceSource returns a NonceSource that yields nonces of the form
+// LE64(counter) || 4 random bytes read from rng (crypto/rand if nil), with
+// the counter starting at initial and incrementing on each call.
+//
+// As with SealHedged, if reading from rng fails the random bytes are left
+// as zero. Once the counter would wrap, Next returns
+// ErrNonceSourceExhausted.
+func NewCounterNonceSource(initial uint64, rng io.Reader) NonceSource {
+	return &counterNonceSource{
+		counter: initial,
+		rng:     rng,
+	}
+}
+
+type counterNonceSource struct {
+	sync.Mutex
+
+	counter   uint64
+	rng       io.Reader
+	exhausted bool
+}
+
+func (s *counterNonceSource) Next() ([NonceSize]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	var nonce [NonceSize]byte
+	if s.exhausted {
+		return nonce, ErrNonceSourceExhausted
+	}
+	hedgedNonce(&nonce, s.counter, s.rng)
+	s.counter++
+	s.exhausted = s.counter == 0
+	return nonce, nil
+}
+
+// NonceSequence is a NonceSource that yields nonces of the form
+// LE64(counter) || prefix, where the prefix is 4 random bytes chosen once
+// when the sequence is created, and the counter starts at 0 and increments
+// on each call, in the manner of TLS record nonces.
+//
+// Unlike a purely random nonce, a single sequence will never repeat a
+// nonce, and separate sequences under the same key only collide if their
+// prefixes do. Once the counter would wrap, Next returns
+// ErrNonceSourceExhausted. A NonceSequence is intended to be passed to
+// SealWithNonceSource.
+type NonceSequence struct {
+	mu sync.Mutex
+
+	prefix    [NonceSize - 8]byte
+	counter   uint64
+	exhausted bool
+}
+
+// NewNonceSequence returns a new NonceSequence, with the prefix read from
+// rng (crypto/rand if nil), or an error if reading from rng fails.
+func NewNonceSequence(rng io.Reader) (*NonceSequence, error) {
+	if rng == nil {
+		rng = rand.Reader
+	}
+
+	s := new(NonceSequence)
+	if _, err := io.ReadFull(rng, s.prefix[:]); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next returns the next nonce in the sequence. The nonce is returned as an
+// array rather than a slice, as required by NonceSource.
+func (s *NonceSequence) Next() ([NonceSize]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var nonce [NonceSize]byte
+	if s.exhausted {
+		return nonce, ErrNonceSourceExhausted
+	}
+	binary.LittleEndian.PutUint64(nonce[0:8], s.counter)
+	copy(nonce[8:], s.prefix[:])
+	s.counter++
+	s.exhausted = s.counter == 0
+	return nonce, nil
+}
+
+// SealWithNonceSource seals plaintext under the next nonce from src, and
+// appends nonce || ciphertext || tag to dst, returning the updated slice.
+func (ae *AEAD) SealWithNonceSource(dst []byte, src NonceSource, plaintext, additionalData []byte) ([]byte, error) {
+	nonce, err := src.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := append(dst, nonce[:]...)
+	return ae.Seal(ret, nonce[:], plaintext, additionalData), nil
+}
+
+// SealWithRandomNonce seals plaintext under a nonce read from crypto/rand,
+// and appends nonce || ciphertext || tag to dst, returning the updated
+// slice, or an error if reading from crypto/rand fails.
+//
+// As the nonce is random, the number of messages sealed under a key
+// should be limited to well below 2^48, lest nonces repeat. Should that
+// happen, the usual nonce misuse resistance properties apply.
+func (ae *AEAD) SealWithRandomNonce(dst, plaintext, additionalData []byte) ([]byte, error) {
+	return ae.SealWithNonceSource(dst, randomNonceSource{rand.Reader}, plaintext, additionalData)
+}
+
+// OpenWithPrependedNonce opens nonce || ciphertext || tag, as produced by
+// SealWithRandomNonce, SealWithNonceSource or SealHedged, and appends the
+// plaintext to dst, returning the updated slice. Input too short to hold a
+// nonce and tag fails with ErrInvalidCiphertextSize.
+func (ae *AEAD) OpenWithPrependedNonce(dst, combined, additionalData []byte) ([]byte, error) {
+	return ae.openPrefixed(dst, combined, additionalData)
+}
+
+// randomNonceSource is a NonceSource that reads nonces from rng.
+type randomNonceSource struct {
+	rng io.Reader
+}
+
+func (s randomNonceSource) Next() ([NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+	if _, err := io.ReadFull(s.rng, nonce[:]); err != nil {
+		return nonce, err
+	}
+	return nonce, nil
+}
+
+// SealHedged seals plaintext under a nonce built from counter and fresh
+// randomness read from rng (crypto/rand if nil), and appends
+// nonce || ciphertext || tag to dst, returning the updated slice.
+//
+// The nonce is LE64(counter) || 4 random bytes. If reading from rng fails,
+// the random bytes are left as zero and sealing proceeds with the counter
+// alone, which is safe as long as the counter is not repeated, and even if
+// it is, no worse than nonce reuse with HS1-SIV.
+func (ae *AEAD) SealHedged(dst []byte, counter uint64, rng io.Reader, plaintext, additionalData []byte) ([]byte, error) {
+	var nonce [NonceSize]byte
+	hedgedNonce(&nonce, counter, rng)
+
+	ret := append(dst, nonce[:]...)
+	return ae.Seal(ret, nonce[:], plaintext, additionalData), nil
+}
+
+func hedgedNonce(nonce *[NonceSize]byte, counter uint64, rng io.Reader) {
+	if rng == nil {
+		rng = rand.Reader
+	}
+	binary.LittleEndian.PutUint64(nonce[0:8], counter)
+	if _, err := io.ReadFull(rng, nonce[8:]); err != nil {
+		for i := 8; i < NonceSize; i++ {
+			nonce[i] = 0
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// nonce_test.go - HS1-SIV nonce helper tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"math"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealHedged(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m, ad := []byte("hedged message"), []byte("hedged ad")
+
+	prefix := []byte("prefix")
+	c, err := aead.SealHedged(append([]byte{}, prefix...), 42, nil, m, ad)
+	require.NoError(err, "SealHedged()")
+	require.Equal(prefix, c[:len(prefix)], "SealHedged(): prefix")
+	c = c[len(prefix):]
+	require.Len(c, NonceSize+len(m)+TagSize, "SealHedged(): len")
+	require.EqualValues(42, binary.LittleEndian.Uint64(c[0:8]), "SealHedged(): counter")
+
+	d, err := aead.Open(nil, c[:NonceSize], c[NonceSize:], ad)
+	require.NoError(err, "Open()")
+	require.Equal(m, d, "Open()")
+
+	// The same counter with fresh randomness yields a fresh nonce.
+	c2, _ := aead.SealHedged(nil, 42, nil, m, ad)
+	require.NotEqual(c[:NonceSize], c2[:NonceSize], "SealHedged(): fresh nonce")
+
+	// The randomness is used as provided.
+	rng := bytes.NewReader([]byte{1, 2, 3, 4})
+	c, err = aead.SealHedged(nil, 7, rng, m, ad)
+	require.NoError(err, "SealHedged(fixed rng)")
+	require.Equal([]byte{7, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}, c[:NonceSize], "SealHedged(fixed rng): nonce")
+
+	// A failing rng degrades to the counter alone.
+	c, err = aead.SealHedged(nil, 7, iotest.ErrReader(iotest.ErrTimeout), m, ad)
+	require.NoError(err, "SealHedged(broken rng)")
+	require.Equal([]byte{7, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, c[:NonceSize], "SealHedged(broken rng): nonce")
+	d, err = aead.Open(nil, c[:NonceSize], c[NonceSize:], ad)
+	require.NoError(err, "Open(broken rng)")
+	require.Equal(m, d, "Open(broken rng)")
+}
+
+func TestSealWithRandomNonce(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m, ad := []byte("random nonce message"), []byte("random nonce ad")
+
+	prefix := []byte("prefix")
+	c, err := aead.SealWithRandomNonce(append([]byte{}, prefix...), m, ad)
+	require.NoError(err, "SealWithRandomNonce()")
+	require.Equal(prefix, c[:len(prefix)], "SealWithRandomNonce(): prefix")
+	c = c[len(prefix):]
+	require.Len(c, NonceSize+len(m)+TagSize, "SealWithRandomNonce(): len")
+
+	c2, err := aead.SealWithRandomNonce(nil, m, ad)
+	require.NoError(err, "SealWithRandomNonce()")
+	require.NotEqual(c[:NonceSize], c2[:NonceSize], "SealWithRandomNonce(): fresh nonce")
+
+	d, err := aead.OpenWithPrependedNonce(append([]byte{}, prefix...), c, ad)
+	require.NoError(err, "OpenWithPrependedNonce()")
+	require.Equal(append(append([]byte{}, prefix...), m...), d, "OpenWithPrependedNonce()")
+
+	_, err = aead.OpenWithPrependedNonce(nil, c, nil)
+	require.Equal(ErrOpen, err, "OpenWithPrependedNonce(wrong AD)")
+	_, err = aead.OpenWithPrependedNonce(nil, c[:NonceSize+TagSize-1], ad)
+	require.Equal(ErrInvalidCiphertextSize, err, "OpenWithPrependedNonce(short)")
+
+	// A failing rng is an error, rather than a predictable nonce.
+	src := randomNonceSource{iotest.ErrReader(iotest.ErrTimeout)}
+	c, err = aead.SealWithNonceSource(nil, src, m, ad)
+	require.Equal(iotest.ErrTimeout, err, "SealWithNonceSource(broken rng)")
+	require.Nil(c, "SealWithNonceSource(broken rng)")
+}
+
+func TestNonceSequence(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m, ad := []byte("nonce sequence message"), []byte("nonce sequence ad")
+
+	seq, err := NewNonceSequence(bytes.NewReader([]byte{1, 2, 3, 4}))
+	require.NoError(err, "NewNonceSequence()")
+	for i := 0; i < 3; i++ {
+		c, err := aead.SealWithNonceSource(nil, seq, m, ad)
+		require.NoError(err, "SealWithNonceSource(): %d", i)
+		require.Equal([]byte{byte(i), 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4}, c[:NonceSize], "SealWithNonceSource(): nonce %d", i)
+
+		d, err := aead.Open(nil, c[:NonceSize], c[NonceSize:], ad)
+		require.NoError(err, "Open(): %d", i)
+		require.Equal(m, d, "Open(): %d", i)
+	}
+
+	// Separate sequences have separate prefixes.
+	seq, err = NewNonceSequence(nil)
+	require.NoError(err, "NewNonceSequence(nil)")
+	seq2, err := NewNonceSequence(nil)
+	require.NoError(err, "NewNonceSequence(nil)")
+	n, _ := seq.Next()
+	n2, _ := seq2.Next()
+	require.NotEqual(n, n2, "Next(): distinct prefixes")
+
+	// The counter refuses to wrap.
+	seq.counter = math.MaxUint64
+	n, err = seq.Next()
+	require.NoError(err, "Next(): last")
+	require.Equal([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, n[:8], "Next(): last")
+	_, err = seq.Next()
+	require.Equal(ErrNonceSourceExhausted, err, "Next(): wrapped")
+	_, err = seq.Next()
+	require.Equal(ErrNonceSourceExhausted, err, "Next(): wrapped again")
+
+	_, err = NewNonceSequence(iotest.ErrReader(iotest.ErrTimeout))
+	require.Equal(iotest.ErrTimeout, err, "NewNonceSequence(broken rng)")
+}
+
+// fakeNonceSource is a NonceSource test double, standing in for something
+// like a hardware counter.
+type fakeNonceSource struct {
+	nonces [][NonceSize]byte
+}
+
+func (s *fakeNonceSource) Next() ([NonceSize]byte, error) {
+	if len(s.nonces) == 0 {
+		return [NonceSize]byte{}, ErrNonceSourceExhausted
+	}
+	nonce := s.nonces[0]
+	s.nonces = s.nonces[1:]
+	return nonce, nil
+}
+
+func TestNonceSource(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m, ad := []byte("nonce source message"), []byte("nonce source ad")
+
+	// The test double's nonces are used as is.
+	src := &fakeNonceSource{nonces: [][NonceSize]byte{{1}, {2}}}
+	for _, expected := range [][NonceSize]byte{{1}, {2}} {
+		c, err := aead.SealWithNonceSource(nil, src, m, ad)
+		require.NoError(err, "SealWithNonceSource()")
+		require.Equal(expected[:], c[:NonceSize], "SealWithNonceSource(): nonce")
+
+		d, err := aead.Open(nil, c[:NonceSize], c[NonceSize:], ad)
+		require.NoError(err, "Open()")
+		require.Equal(m, d, "Open()")
+	}
+	_, err := aead.SealWithNonceSource(nil, src, m, ad)
+	require.Equal(ErrNonceSourceExhausted, err, "SealWithNonceSource(exhausted)")
+
+	// The default source increments the counter, and refuses to wrap.
+	counterSrc := NewCounterNonceSource(math.MaxUint64-1, bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	n, err := counterSrc.Next()
+	require.NoError(err, "counter Next()")
+	require.Equal([NonceSize]byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 1, 2, 3, 4}, n, "counter Next()")
+	n, err = counterSrc.Next()
+	require.NoError(err, "counter Next()")
+	require.Equal([NonceSize]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 5, 6, 7, 8}, n, "counter Next()")
+	_, err = counterSrc.Next()
+	require.Equal(ErrNonceSourceExhausted, err, "counter Next(): wrapped")
+}
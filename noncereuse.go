@@ -0,0 +1,14 @@
+// noncereuse.go - HS1-SIV nonce reuse detection
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "errors"
+
+// ErrNonceReused is the error thrown via a panic by the AEAD returned from
+// NewNonceReuseDetector, when a nonce is reused.
+var ErrNonceReused = errors.New("hs1siv: nonce reused")
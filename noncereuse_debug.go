@@ -0,0 +1,66 @@
+// noncereuse_debug.go - HS1-SIV nonce reuse detection (enabled)
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build hs1siv_debug
+
+package hs1siv
+
+import (
+	"crypto/cipher"
+	"sync"
+)
+
+// NewNonceReuseDetector wraps aead such that Seal will panic with
+// ErrNonceReused if a nonce is reused. Only the maxTracked most recently
+// used nonces are remembered.
+//
+// Nonce reuse detection is only done when built with the `hs1siv_debug`
+// tag, otherwise aead is returned as is.
+func NewNonceReuseDetector(aead cipher.AEAD, maxTracked int) cipher.AEAD {
+	if maxTracked < 1 {
+		maxTracked = 1
+	}
+	return &nonceReuseDetector{
+		AEAD: aead,
+		seen: make(map[string]struct{}, maxTracked),
+		ring: make([]string, 0, maxTracked),
+	}
+}
+
+type nonceReuseDetector struct {
+	cipher.AEAD
+
+	sync.Mutex
+	seen map[string]struct{}
+	ring []string
+	next int
+}
+
+func (d *nonceReuseDetector) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	d.observe(nonce)
+	return d.AEAD.Seal(dst, nonce, plaintext, additionalData)
+}
+
+func (d *nonceReuseDetector) observe(nonce []byte) {
+	d.Lock()
+	defer d.Unlock()
+
+	k := string(nonce)
+	if _, ok := d.seen[k]; ok {
+		panic(ErrNonceReused)
+	}
+
+	// Evict the oldest nonce once full.
+	if len(d.ring) < cap(d.ring) {
+		d.ring = append(d.ring, k)
+	} else {
+		delete(d.seen, d.ring[d.next])
+		d.ring[d.next] = k
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.seen[k] = struct{}{}
+}
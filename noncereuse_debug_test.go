@@ -0,0 +1,47 @@
+// noncereuse_debug_test.go - HS1-SIV nonce reuse detection tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build hs1siv_debug
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonceReuseDetector(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := NewNonceReuseDetector(New(key[:]), 2)
+
+	n1, n2, n3 := make([]byte, NonceSize), make([]byte, NonceSize), make([]byte, NonceSize)
+	n1[0], n2[0], n3[0] = 1, 2, 3
+
+	c := aead.Seal(nil, n1, []byte("m1"), nil)
+	m, err := aead.Open(nil, n1, c, nil)
+	require.NoError(err, "Open()")
+	require.Equal([]byte("m1"), m, "Open()")
+
+	_ = aead.Seal(nil, n2, nil, nil)
+	require.PanicsWithValue(ErrNonceReused, func() {
+		_ = aead.Seal(nil, n1, []byte("m2"), nil)
+	}, "Seal(reused n1)")
+
+	// n1 is evicted once n3 is used, and is forgotten.
+	_ = aead.Seal(nil, n3, nil, nil)
+	require.NotPanics(func() {
+		_ = aead.Seal(nil, n1, nil, nil)
+	}, "Seal(evicted n1)")
+	require.PanicsWithValue(ErrNonceReused, func() {
+		_ = aead.Seal(nil, n3, nil, nil)
+	}, "Seal(reused n3)")
+}
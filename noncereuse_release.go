@@ -0,0 +1,22 @@
+// noncereuse_release.go - HS1-SIV nonce reuse detection (disabled)
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build !hs1siv_debug
+
+package hs1siv
+
+import "crypto/cipher"
+
+// NewNonceReuseDetector wraps aead such that Seal will panic with
+// ErrNonceReused if a nonce is reused. Only the maxTracked most recently
+// used nonces are remembered.
+//
+// Nonce reuse detection is only done when built with the `hs1siv_debug`
+// tag, otherwise aead is returned as is.
+func NewNonceReuseDetector(aead cipher.AEAD, maxTracked int) cipher.AEAD {
+	return aead
+}
@@ -0,0 +1,85 @@
+// padded.go - HS1-SIV length hiding padding
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// paddedLenSize is the size of the length prefix of a padded plaintext.
+const paddedLenSize = 8
+
+// ErrInvalidPadding is the error returned when an authenticated plaintext
+// is not correctly padded.
+var ErrInvalidPadding = errors.New("hs1siv: invalid padding")
+
+// SealPadded encrypts and authenticates plaintext as with Seal, after
+// padding it so that the ciphertext length only reveals which multiple of
+// bucketSize the plaintext fits in.
+//
+// The padded plaintext is LE64(len(plaintext)) || plaintext || zeros,
+// rounded up to the next multiple of bucketSize, so the true length is
+// encrypted and authenticated. The ciphertext is always at least
+// bucketSize + Overhead() bytes.
+func (ae *AEAD) SealPadded(dst, nonce, plaintext, additionalData []byte, bucketSize int) []byte {
+	if bucketSize <= 0 {
+		panic("hs1siv: invalid bucket size")
+	}
+
+	paddedLen := paddedLenSize + len(plaintext)
+	if r := paddedLen % bucketSize; r != 0 {
+		paddedLen += bucketSize - r
+	}
+	if uint64(paddedLen) > MaxPlaintextSize || paddedLen < len(plaintext) {
+		panic(ErrInvalidPlaintextSize)
+	}
+
+	buf := make([]byte, paddedLen)
+	binary.LittleEndian.PutUint64(buf, uint64(len(plaintext)))
+	copy(buf[paddedLenSize:], plaintext)
+	ret := ae.Seal(dst, nonce, buf, additionalData)
+	for i := range buf {
+		buf[i] = 0
+	}
+	return ret
+}
+
+// OpenPadded decrypts and authenticates the output of SealPadded as with
+// Open, and removes the padding.
+func (ae *AEAD) OpenPadded(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	buf, err := ae.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range buf {
+			buf[i] = 0
+		}
+	}()
+
+	if len(buf) < paddedLenSize {
+		return nil, ErrInvalidPadding
+	}
+	l := binary.LittleEndian.Uint64(buf)
+	if l > uint64(len(buf)-paddedLenSize) {
+		return nil, ErrInvalidPadding
+	}
+	for _, v := range buf[paddedLenSize+int(l):] {
+		if v != 0 {
+			return nil, ErrInvalidPadding
+		}
+	}
+
+	ret := append(dst, buf[paddedLenSize:paddedLenSize+int(l)]...)
+	if ret == nil {
+		// Match Open, which never returns nil on success.
+		ret = []byte{}
+	}
+	return ret, nil
+}
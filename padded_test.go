@@ -0,0 +1,71 @@
+// padded_test.go - HS1-SIV length hiding padding tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealPadded(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	const bucketSize = 64
+	ad := []byte("padded ad")
+	for _, v := range []struct {
+		sizes     []int
+		sealedLen int
+	}{
+		{[]int{0, 1, bucketSize - paddedLenSize}, bucketSize + TagSize},
+		{[]int{bucketSize - paddedLenSize + 1, bucketSize, 2*bucketSize - paddedLenSize}, 2*bucketSize + TagSize},
+		{[]int{1000}, 16*bucketSize + TagSize},
+	} {
+		for _, sz := range v.sizes {
+			m := make([]byte, sz)
+			_, _ = rand.Read(m)
+
+			c := aead.SealPadded(nil, nonce[:], m, ad, bucketSize)
+			require.Len(c, v.sealedLen, "SealPadded(): %d", sz)
+
+			pt, err := aead.OpenPadded(nil, nonce[:], c, ad)
+			require.NoError(err, "OpenPadded(): %d", sz)
+			require.Equal(m, pt, "OpenPadded(): %d", sz)
+
+			pt, err = aead.OpenPadded([]byte("dst"), nonce[:], c, ad)
+			require.NoError(err, "OpenPadded(dst): %d", sz)
+			require.Equal(append([]byte("dst"), m...), pt, "OpenPadded(dst): %d", sz)
+
+			c[0] ^= 1
+			_, err = aead.OpenPadded(nil, nonce[:], c, ad)
+			require.Equal(ErrOpen, err, "OpenPadded(tampered): %d", sz)
+		}
+	}
+
+	// Authenticated but malformed padding is rejected.
+	for _, pt := range [][]byte{
+		{},
+		{1, 0, 0, 0, 0, 0, 0},
+		{1, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 1},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0},
+	} {
+		c := aead.Seal(nil, nonce[:], pt, ad)
+		_, err := aead.OpenPadded(nil, nonce[:], c, ad)
+		require.Equal(ErrInvalidPadding, err, "OpenPadded(%x)", pt)
+	}
+
+	require.Panics(func() { aead.SealPadded(nil, nonce[:], nil, ad, 0) }, "SealPadded(bucketSize = 0)")
+}
@@ -0,0 +1,116 @@
+// params.go - HS1-SIV parameter sets
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidParams is the error returned when a parameter set is invalid.
+var ErrInvalidParams = errors.New("hs1siv: invalid parameters")
+
+// Params is a HS1-SIV parameter set.
+type Params struct {
+	// NHLen is the NH block length in bytes (parameter b).
+	NHLen int
+
+	// HashRounds is the number of parallel hash instances (parameter t).
+	HashRounds int
+
+	// SIVLen is the length of the SIV, and thus the tag, in bytes
+	// (parameter l).
+	SIVLen int
+
+	// ChaChaRounds is the number of ChaCha rounds (parameter r).
+	ChaChaRounds int
+
+	// truncatedFrom is the SIV length bound by the key schedule, if the
+	// SIV is a truncation of a longer one (see NewTruncated), or 0.
+	truncatedFrom int
+}
+
+// ParamsHi returns the "hs1-siv-hi" parameter set, as used by New.
+func ParamsHi() Params {
+	return paramsHi
+}
+
+// ParamsMed returns the "hs1-siv-med" parameter set, as used by NewMed.
+func ParamsMed() Params {
+	return paramsMed
+}
+
+// ParamsLo returns the "hs1-siv-lo" parameter set.
+func ParamsLo() Params {
+	return paramsLo
+}
+
+var (
+	paramsHi = Params{
+		NHLen:        hs1NHLen,
+		HashRounds:   hs1HashRounds,
+		SIVLen:       hs1SIVLen,
+		ChaChaRounds: chacha20Rounds,
+	}
+	paramsMed = Params{
+		NHLen:        64,
+		HashRounds:   4,
+		SIVLen:       16,
+		ChaChaRounds: 12,
+	}
+	paramsLo = Params{
+		NHLen:        64,
+		HashRounds:   2,
+		SIVLen:       8,
+		ChaChaRounds: 8,
+	}
+)
+
+// Validate returns nil iff the parameter set is supported.
+//
+// The specification allows a wider range of values than this implementation,
+// which only supports the NH block length shared by all of the standard
+// parameter sets, an even number of hash rounds up to that of
+// "hs1-siv-hi", a SIV of 8 to 32 bytes, and ChaCha8/12/20.
+func (p *Params) Validate() error {
+	switch {
+	case p.NHLen != hs1NHLen:
+		return fmt.Errorf("%w: NHLen %d", ErrInvalidParams, p.NHLen)
+	case p.HashRounds < 2 || p.HashRounds > hs1HashRounds || p.HashRounds%2 != 0:
+		return fmt.Errorf("%w: HashRounds %d", ErrInvalidParams, p.HashRounds)
+	case p.SIVLen < 8 || p.SIVLen > hs1SIVLen:
+		return fmt.Errorf("%w: SIVLen %d", ErrInvalidParams, p.SIVLen)
+	}
+	switch p.ChaChaRounds {
+	case 8, 12, chacha20Rounds:
+	default:
+		return fmt.Errorf("%w: ChaChaRounds %d", ErrInvalidParams, p.ChaChaRounds)
+	}
+	return nil
+}
+
+// settings returns the key expansion nonce for the parameter set, which
+// encodes the key length and the parameters. As every subkey is derived
+// under it, a ciphertext is implicitly bound to the parameter set it was
+// sealed with, and will fail to authenticate under any other, even with the
+// same key.
+func (p *Params) settings(keyLen int) [chacha20NonceSize]byte {
+	sivLen := p.SIVLen
+	if p.truncatedFrom != 0 {
+		sivLen = p.truncatedFrom
+	}
+	return [chacha20NonceSize]byte{
+		byte(keyLen), 0, byte(sivLen), 0, byte(p.ChaChaRounds), byte(p.HashRounds), byte(p.NHLen),
+		0, 0, 0, 0, 0,
+	}
+}
+
+// stateSize returns the size of the expanded key schedule in bytes.
+func (p *Params) stateSize() int {
+	return chacha20KeySize + hashStateSizeFor(p.HashRounds)
+}
@@ -0,0 +1,143 @@
+// params_test.go - HS1-SIV parameter set tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamsValidate(t *testing.T) {
+	require := require.New(t)
+
+	for _, p := range []Params{ParamsHi(), ParamsMed(), ParamsLo()} {
+		require.NoError(p.Validate(), "Validate(): %+v", p)
+	}
+
+	// The standard parameter sets are returned by value, so modifying them
+	// does not affect the constructors.
+	p := ParamsHi()
+	p.SIVLen = 8
+	require.Equal(ParamsHi(), New(make([]byte, KeySize)).Params(), "ParamsHi() modified")
+
+	for _, p := range []Params{
+		{},
+		{NHLen: 32, HashRounds: 6, SIVLen: 32, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 0, SIVLen: 32, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 3, SIVLen: 32, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 8, SIVLen: 32, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 6, SIVLen: 4, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 6, SIVLen: 33, ChaChaRounds: 20},
+		{NHLen: 64, HashRounds: 6, SIVLen: 32, ChaChaRounds: 10},
+	} {
+		err := p.Validate()
+		require.True(errors.Is(err, ErrInvalidParams), "Validate(): %+v: %v", p, err)
+	}
+}
+
+func TestNewWithParams(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	m := []byte("a message sealed with an explicit parameter set")
+
+	_, err := NewWithParams(key[:0], ParamsHi())
+	require.Equal(ErrInvalidKeySize, err, "NewWithParams(empty key)")
+	_, err = NewWithParams(key[:], Params{})
+	require.True(errors.Is(err, ErrInvalidParams), "NewWithParams(invalid): %v", err)
+
+	// The standard parameter sets match their dedicated constructors.
+	for _, v := range []struct {
+		p  Params
+		ae *AEAD
+	}{
+		{ParamsHi(), New(key[:])},
+		{ParamsMed(), NewMed(key[:])},
+	} {
+		ae, err := NewWithParams(key[:], v.p)
+		require.NoError(err, "NewWithParams(%+v)", v.p)
+		require.True(ae.CompatibleWith(v.ae), "CompatibleWith(): %+v", v.p)
+		require.Equal(v.ae.Seal(nil, nonce[:], m, nil), ae.Seal(nil, nonce[:], m, nil), "Seal(): %+v", v.p)
+	}
+
+	// Non-standard combinations work, and are distinct from the standard
+	// parameter sets.
+	p := ParamsHi()
+	p.ChaChaRounds = 12
+	ae, err := NewWithParams(key[:], p)
+	require.NoError(err, "NewWithParams(custom)")
+	require.False(ae.CompatibleWith(New(key[:])), "CompatibleWith(): custom")
+	c := ae.Seal(nil, nonce[:], m, nil)
+	pt, err := ae.Open(nil, nonce[:], c, nil)
+	require.NoError(err, "Open(): custom")
+	require.Equal(m, pt, "Open(): custom")
+	_, err = New(key[:]).Open(nil, nonce[:], c, nil)
+	require.Equal(ErrOpen, err, "New().Open(custom)")
+
+	// The instance holds a copy of the parameters.
+	p.ChaChaRounds = 8
+	require.Equal(12, ae.p.ChaChaRounds, "ChaChaRounds after modification")
+}
+
+func TestAEADParams(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+
+	custom := ParamsMed()
+	custom.SIVLen = 24
+	customAEAD, err := NewWithParams(key[:], custom)
+	require.NoError(err, "NewWithParams()")
+
+	for _, v := range []struct {
+		ae *AEAD
+		p  Params
+	}{
+		{New(key[:]), ParamsHi()},
+		{NewMed(key[:]), ParamsMed()},
+		{NewTyped(key[:], []byte("label")), ParamsHi()},
+		{customAEAD, custom},
+	} {
+		p := v.ae.Params()
+		require.Equal(v.p, p, "Params()")
+		require.Equal(p.SIVLen, v.ae.Overhead(), "Overhead()")
+		require.Equal(NonceSize, v.ae.NonceSize(), "NonceSize()")
+
+		// The returned value is a copy.
+		p.SIVLen = 0
+		require.Equal(v.p, v.ae.Params(), "Params() after modification")
+	}
+}
+
+func TestParamsKAT(t *testing.T) {
+	require := require.New(t)
+
+	_, _, k, n := katInputs()
+
+	// hs1-siv-hi against the reference implementation KAT.
+	ae, err := NewWithParams(k[:], ParamsHi())
+	require.NoError(err, "NewWithParams(ParamsHi)")
+	require.Equal(kaths1siv[:TagSize], ae.Seal(nil, n[:], nil, nil), "Seal(): hi")
+
+	// hs1-siv-med and hs1-siv-lo against the self-generated regression
+	// values, see TestMedRegression.
+	ae, err = NewWithParams(k[:], ParamsMed())
+	require.NoError(err, "NewWithParams(ParamsMed)")
+	testKATDigest(t, ae, 16, katMedEmpty, katMedDigest)
+
+	ae, err = NewWithParams(k[:], ParamsLo())
+	require.NoError(err, "NewWithParams(ParamsLo)")
+	testKATDigest(t, ae, 8, katLoEmpty, katLoDigest)
+}
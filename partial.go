@@ -0,0 +1,50 @@
+// partial.go - HS1-SIV with a cleartext header
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// SealPartial encrypts and authenticates plaintext, authenticates (but does
+// not encrypt) header, and appends header || ciphertext || tag to dst,
+// returning the updated slice. The header is used as the additional data.
+//
+// Neither the header nor the plaintext may overlap dst.
+func (ae *AEAD) SealPartial(dst, nonce, header, plaintext []byte) []byte {
+	ret, out := sliceForAppend(dst, len(header))
+	copy(out, header)
+	return ae.Seal(ret, nonce, plaintext, out)
+}
+
+// OpenPartial decrypts and authenticates the output of SealPartial, where
+// the first headerLen bytes of sealed are the cleartext header, and if
+// successful, appends the resulting plaintext to dst, returning the header
+// and the updated slice.
+//
+// The ciphertext portion of sealed and dst must overlap exactly or not at
+// all.
+func (ae *AEAD) OpenPartial(dst, nonce, sealed []byte, headerLen int) (header, plaintext []byte, err error) {
+	if headerLen < 0 || len(sealed)-headerLen < ae.Overhead() {
+		return nil, nil, ErrInvalidCiphertextSize
+	}
+
+	header = sealed[:headerLen:headerLen]
+	if plaintext, err = ae.Open(dst, nonce, sealed[headerLen:], header); err != nil {
+		return nil, nil, err
+	}
+	return header, plaintext, nil
+}
+
+// PeekHeader returns the cleartext header of the output of SealPartial with
+// the default parameter set, without decrypting or authenticating anything,
+// or nil if sealed is too short. The returned slice aliases sealed.
+//
+// The header MUST be treated as untrusted until OpenPartial succeeds.
+func PeekHeader(sealed []byte, headerLen int) []byte {
+	if headerLen < 0 || len(sealed)-headerLen < TagSize {
+		return nil
+	}
+	return sealed[:headerLen:headerLen]
+}
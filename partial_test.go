@@ -0,0 +1,60 @@
+// partial_test.go - HS1-SIV with a cleartext header tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealPartial(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	header := []byte("route: shard-7")
+	m := []byte("the actual payload, which is secret")
+
+	prefix := []byte("prefix")
+	sealed := aead.SealPartial(append([]byte{}, prefix...), nonce[:], header, m)
+	require.Equal(prefix, sealed[:len(prefix)], "SealPartial(): prefix")
+	sealed = sealed[len(prefix):]
+	require.Len(sealed, len(header)+len(m)+TagSize, "SealPartial(): len")
+
+	// The header is readable without the key.
+	require.Equal(header, PeekHeader(sealed, len(header)), "PeekHeader()")
+	require.Nil(PeekHeader(sealed, len(sealed)), "PeekHeader(too long)")
+	require.Nil(PeekHeader(sealed, -1), "PeekHeader(negative)")
+
+	// And equivalent to Seal with the header as AD.
+	require.Equal(aead.Seal(nil, nonce[:], m, header), sealed[len(header):], "SealPartial() vs Seal()")
+
+	h, d, err := aead.OpenPartial(nil, nonce[:], sealed, len(header))
+	require.NoError(err, "OpenPartial()")
+	require.Equal(header, h, "OpenPartial(): header")
+	require.Equal(m, d, "OpenPartial(): plaintext")
+
+	// Tampering with the header is detected.
+	badSealed := append([]byte{}, sealed...)
+	badSealed[0] ^= 0x23
+	_, _, err = aead.OpenPartial(nil, nonce[:], badSealed, len(header))
+	require.Equal(ErrOpen, err, "OpenPartial(bad header)")
+
+	// As is splitting at the wrong offset.
+	_, _, err = aead.OpenPartial(nil, nonce[:], sealed, len(header)-1)
+	require.Equal(ErrOpen, err, "OpenPartial(bad headerLen)")
+
+	_, _, err = aead.OpenPartial(nil, nonce[:], sealed, len(sealed))
+	require.Equal(ErrInvalidCiphertextSize, err, "OpenPartial(too long)")
+}
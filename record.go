@@ -0,0 +1,64 @@
+// record.go - HS1-SIV record field sealing
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "encoding/binary"
+
+// RecordBaseNonceSize is the size of a RecordSealer base nonce in bytes.
+const RecordBaseNonceSize = NonceSize - 4
+
+// RecordSealer seals the individual fields of a record, such that each
+// field is bound to its index in the record and to the record's schema
+// version. Fields can not be swapped within a record, or opened under a
+// different schema version.
+//
+// The nonce for each field is base nonce || LE32(index), so the base nonce
+// should be unique per record.
+type RecordSealer struct {
+	ae        *AEAD
+	baseNonce [RecordBaseNonceSize]byte
+}
+
+// NewRecordSealer returns a new RecordSealer for a record, with the given
+// base nonce.
+func NewRecordSealer(ae *AEAD, baseNonce []byte) (*RecordSealer, error) {
+	if len(baseNonce) != RecordBaseNonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	rs := &RecordSealer{
+		ae: ae,
+	}
+	copy(rs.baseNonce[:], baseNonce)
+	return rs, nil
+}
+
+// SealField seals the field at index of the record, and appends the result
+// to dst, returning the updated slice.
+func (rs *RecordSealer) SealField(dst []byte, index uint32, plaintext []byte, schemaVersion uint32) []byte {
+	var nonce [NonceSize]byte
+	var ad [8]byte
+	rs.fieldParams(index, schemaVersion, &nonce, &ad)
+	return rs.ae.Seal(dst, nonce[:], plaintext, ad[:])
+}
+
+// OpenField opens the field at index of the record, and if successful,
+// appends the resulting plaintext to dst, returning the updated slice.
+func (rs *RecordSealer) OpenField(dst []byte, index uint32, sealed []byte, schemaVersion uint32) ([]byte, error) {
+	var nonce [NonceSize]byte
+	var ad [8]byte
+	rs.fieldParams(index, schemaVersion, &nonce, &ad)
+	return rs.ae.Open(dst, nonce[:], sealed, ad[:])
+}
+
+func (rs *RecordSealer) fieldParams(index, schemaVersion uint32, nonce *[NonceSize]byte, ad *[8]byte) {
+	copy(nonce[:], rs.baseNonce[:])
+	binary.LittleEndian.PutUint32(nonce[RecordBaseNonceSize:], index)
+	binary.LittleEndian.PutUint32(ad[0:4], schemaVersion)
+	binary.LittleEndian.PutUint32(ad[4:8], index)
+}
@@ -0,0 +1,68 @@
+// record_test.go - HS1-SIV record field sealing tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSealer(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var baseNonce [RecordBaseNonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(baseNonce[:])
+	aead := New(key[:])
+
+	rs, err := NewRecordSealer(aead, baseNonce[:])
+	require.NoError(err, "NewRecordSealer()")
+
+	const schemaVersion = 3
+	fields := [][]byte{
+		[]byte("alice"),
+		[]byte("alice@example.com"),
+		[]byte("admin"),
+	}
+	sealed := make([][]byte, 0, len(fields))
+	for i, f := range fields {
+		sealed = append(sealed, rs.SealField(nil, uint32(i), f, schemaVersion))
+	}
+
+	for i, s := range sealed {
+		d, err := rs.OpenField(nil, uint32(i), s, schemaVersion)
+		require.NoError(err, "OpenField(%d)", i)
+		require.Equal(fields[i], d, "OpenField(%d)", i)
+
+		// Cross-field.
+		for j := range sealed {
+			if i == j {
+				continue
+			}
+			_, err = rs.OpenField(nil, uint32(j), s, schemaVersion)
+			require.Equal(ErrOpen, err, "OpenField(%d as %d)", i, j)
+		}
+
+		// Cross-version.
+		_, err = rs.OpenField(nil, uint32(i), s, schemaVersion+1)
+		require.Equal(ErrOpen, err, "OpenField(%d, other version)", i)
+	}
+
+	// Cross-record.
+	otherNonce := append([]byte{}, baseNonce[:]...)
+	otherNonce[0] ^= 1
+	rs2, _ := NewRecordSealer(aead, otherNonce)
+	_, err = rs2.OpenField(nil, 0, sealed[0], schemaVersion)
+	require.Equal(ErrOpen, err, "OpenField(other record)")
+
+	_, err = NewRecordSealer(aead, baseNonce[1:])
+	require.Equal(ErrInvalidNonceSize, err, "NewRecordSealer(bad nonce)")
+}
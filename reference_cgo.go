@@ -0,0 +1,49 @@
+// reference_cgo.go - HS1-SIV C reference implementation bindings
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build cgo_reference
+
+package hs1siv
+
+// #cgo CFLAGS: -I${SRCDIR}/testdata/hs1sivhiv2 -I${SRCDIR}/testdata/hs1sivhiv2/ref
+// #include "crypto_aead.h"
+// #include "api.h"
+// #include "ref/encrypt.c"
+import "C"
+
+import "unsafe"
+
+const (
+	refKeySize   = C.CRYPTO_KEYBYTES
+	refNonceSize = C.CRYPTO_NPUBBYTES
+	refTagSize   = C.CRYPTO_ABYTES
+)
+
+// refSeal seals plaintext with the C reference implementation. It exists
+// only to allow cross-checking this package, and is not built by default.
+func refSeal(key, nonce, plaintext, additionalData []byte) []byte {
+	c := make([]byte, len(plaintext)+refTagSize)
+	var cLen C.ulonglong
+	if ret := C.crypto_aead_encrypt(
+		(*C.uchar)(unsafe.Pointer(&c[0])), &cLen,
+		cBytes(plaintext), C.ulonglong(len(plaintext)),
+		cBytes(additionalData), C.ulonglong(len(additionalData)),
+		nil,
+		cBytes(nonce),
+		cBytes(key),
+	); ret != 0 {
+		panic("hs1siv: reference crypto_aead_encrypt failed")
+	}
+	return c[:cLen]
+}
+
+func cBytes(b []byte) *C.uchar {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*C.uchar)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,105 @@
+// reference_cgo_test.go - HS1-SIV C reference implementation cross-check
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+//go:build cgo_reference
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// refParams returns the parameter set that the reference implementation was
+// built with, as selected by HS1SIV_REFERENCE_PARAMS (hi, med or lo), which
+// defaults to hi.
+func refParams(t *testing.T) Params {
+	switch v := os.Getenv("HS1SIV_REFERENCE_PARAMS"); v {
+	case "", "hi":
+		return ParamsHi()
+	case "med":
+		return ParamsMed()
+	case "lo":
+		return ParamsLo()
+	default:
+		t.Fatalf("invalid HS1SIV_REFERENCE_PARAMS: %q", v)
+		return Params{}
+	}
+}
+
+func TestReference(t *testing.T) {
+	require := require.New(t)
+
+	p := refParams(t)
+	require.True(isValidKeySize(refKeySize), "CRYPTO_KEYBYTES: %d", refKeySize)
+	require.Equal(NonceSize, refNonceSize, "CRYPTO_NPUBBYTES")
+	require.Equal(p.SIVLen, refTagSize, "CRYPTO_ABYTES")
+
+	newAEAD := func(key []byte) *AEAD {
+		aead, err := NewWithParams(key, p)
+		require.NoError(err, "NewWithParams(%+v)", p)
+		return aead
+	}
+
+	// The KAT inputs, and the known good values for the parameter set and
+	// key size if this package has any. As with TestKey128Regression, a short key is
+	// the prefix of the KAT key.
+	w, h, k, n := katInputs()
+	aead := newAEAD(k[:refKeySize])
+	var refAcc []byte
+	for i := range w {
+		expected := refSeal(k[:refKeySize], n[:], w[:i], h[:i])
+		require.Equal(expected, aead.Seal(nil, n[:], w[:i], h[:i]), "Seal(): KAT %d", i)
+		refAcc = append(refAcc, expected...)
+	}
+	refDigest := sha256.Sum256(refAcc)
+	t.Logf("%+v, %d byte key: empty: %x digest: %x", p, refKeySize, refAcc[:refTagSize], refDigest)
+	hiDigest := sha256.Sum256(kaths1siv)
+	for _, v := range []struct {
+		p             Params
+		keySize       int
+		empty, digest string
+	}{
+		{ParamsHi(), KeySize, hex.EncodeToString(kaths1siv[:TagSize]), hex.EncodeToString(hiDigest[:])},
+		{ParamsHi(), KeySize128, kat128Empty, kat128Digest},
+		{ParamsMed(), KeySize, katMedEmpty, katMedDigest},
+		{ParamsLo(), KeySize, katLoEmpty, katLoDigest},
+	} {
+		if v.p != p || v.keySize != refKeySize {
+			continue
+		}
+		require.Equal(v.empty, hex.EncodeToString(refAcc[:refTagSize]), "KAT: empty")
+		require.Equal(v.digest, hex.EncodeToString(refDigest[:]), "KAT: SHA-256 of concatenated cipher texts")
+	}
+
+	randLen := func(max int64) int {
+		n, err := rand.Int(rand.Reader, big.NewInt(max))
+		require.NoError(err, "rand.Int()")
+		return int(n.Int64())
+	}
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	for i := 0; i < 1000; i++ {
+		_, _ = rand.Read(key[:])
+		_, _ = rand.Read(nonce[:])
+		m := make([]byte, randLen(4096))
+		ad := make([]byte, randLen(512))
+		_, _ = rand.Read(m)
+		_, _ = rand.Read(ad)
+
+		expected := refSeal(key[:refKeySize], nonce[:], m, ad)
+		c := newAEAD(key[:refKeySize]).Seal(nil, nonce[:], m, ad)
+		require.Equal(expected, c, "Seal(): %d (m: %d ad: %d)", i, len(m), len(ad))
+	}
+}
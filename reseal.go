@@ -0,0 +1,32 @@
+// reseal.go - HS1-SIV re-sealing
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// Reseal opens sealed under oldNonce and oldAD, and seals the plaintext
+// under newNonce and newAD, returning the result in a newly allocated slice.
+//
+// As the SIV depends on the additional data and keys the encryption, this
+// re-encrypts the entire message. The intermediate plaintext is never held
+// outside of the output buffer, where it is either overwritten by the new
+// ciphertext, or zeroed if authentication fails.
+func (ae *AEAD) Reseal(oldNonce, sealed, oldAD, newNonce, newAD []byte) (newSealed []byte, err error) {
+	if len(oldNonce) != NonceSize || len(newNonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	if len(sealed) < ae.Overhead() {
+		return nil, ErrOpen
+	}
+
+	// Decrypt into the output buffer, and seal in place.
+	buf := make([]byte, len(sealed))
+	plaintext, err := ae.Open(buf[:0], oldNonce, sealed, oldAD)
+	if err != nil {
+		return nil, err
+	}
+	return ae.Seal(plaintext[:0], newNonce, plaintext, newAD), nil
+}
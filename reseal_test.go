@@ -0,0 +1,50 @@
+// reseal_test.go - HS1-SIV re-sealing tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReseal(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var oldNonce, newNonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(oldNonce[:])
+	_, _ = rand.Read(newNonce[:])
+	aead := New(key[:])
+
+	oldAD, newAD := []byte("label v1"), []byte("label v2")
+	for _, sz := range []int{0, 1, 64, 1000} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		sealed := aead.Seal(nil, oldNonce[:], m, oldAD)
+
+		resealed, err := aead.Reseal(oldNonce[:], sealed, oldAD, newNonce[:], newAD)
+		require.NoError(err, "Reseal(): %d", sz)
+		require.Equal(aead.Seal(nil, newNonce[:], m, newAD), resealed, "Reseal(): %d", sz)
+
+		_, err = aead.Open(nil, newNonce[:], resealed, oldAD)
+		require.Equal(ErrOpen, err, "Open(resealed, old AD): %d", sz)
+
+		_, err = aead.Reseal(oldNonce[:], sealed, newAD, newNonce[:], newAD)
+		require.Equal(ErrOpen, err, "Reseal(wrong AD): %d", sz)
+	}
+
+	_, err := aead.Reseal(oldNonce[:], make([]byte, TagSize-1), nil, newNonce[:], nil)
+	require.Equal(ErrOpen, err, "Reseal(short)")
+	_, err = aead.Reseal(oldNonce[1:], make([]byte, TagSize), nil, newNonce[:], nil)
+	require.Equal(ErrInvalidNonceSize, err, "Reseal(bad old nonce)")
+	_, err = aead.Reseal(oldNonce[:], make([]byte, TagSize), nil, newNonce[1:], nil)
+	require.Equal(ErrInvalidNonceSize, err, "Reseal(bad new nonce)")
+}
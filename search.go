@@ -0,0 +1,40 @@
+// search.go - HS1-SIV deterministic search tokens
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// SearchTokenSize is the size of a search token in bytes.
+const SearchTokenSize = hs1SIVLen
+
+// SearchToken returns a deterministic token for term in the context of the
+// additional data, suitable for equality lookups over encrypted data.
+//
+// The token is the SIV (tag) that Seal would produce for the term and
+// additional data under an all zero nonce. Equal terms in the same
+// context always map to the same token, and anything else maps to
+// unrelated tokens.
+//
+// WARNING: By design this leaks the equality pattern of the terms (and thus
+// their frequency distribution) to anyone who can see the tokens, which for
+// low entropy terms may be enough to recover them. Nothing else is leaked.
+// The additional data should be used to separate unrelated indexes.
+func (ae *AEAD) SearchToken(term, additionalData []byte) []byte {
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+
+	token := make([]byte, ks.p.SIVLen)
+	ctx.sivSetup(len(additionalData), len(term))
+	ctx.sivHashAD(additionalData)
+	ctx.sivGenerate(term, zeroNonce[:], token)
+	return token
+}
@@ -0,0 +1,51 @@
+// search_test.go - HS1-SIV deterministic search token tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchToken(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	emails, names := []byte("index:email"), []byte("index:name")
+	term := []byte("alice@example.com")
+
+	tok := aead.SearchToken(term, emails)
+	require.Len(tok, SearchTokenSize, "SearchToken()")
+	require.Equal(tok, aead.SearchToken(append([]byte{}, term...), emails), "SearchToken(equal term)")
+	require.NotEqual(tok, aead.SearchToken([]byte("bob@example.com"), emails), "SearchToken(different term)")
+	require.NotEqual(tok, aead.SearchToken(term, names), "SearchToken(different context)")
+	require.NotEqual(tok, aead.SearchToken(term, nil), "SearchToken(no context)")
+
+	// The token is the SIV of a deterministic Seal.
+	sealed := aead.Seal(nil, zeroNonce[:], term, emails)
+	require.Equal(sealed[len(term):], tok, "SearchToken() == SIV")
+
+	// Moving the term/context boundary changes the token.
+	require.NotEqual(aead.SearchToken([]byte("ab"), []byte("c")), aead.SearchToken([]byte("b"), []byte("ac")), "SearchToken(boundary)")
+
+	// Tokens under different keys are unrelated.
+	_, _ = rand.Read(key[:])
+	require.NotEqual(tok, New(key[:]).SearchToken(term, emails), "SearchToken(different key)")
+
+	for _, sz := range []int{0, 1, 63, 64, 65, 1000} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+		sealed = aead.Seal(nil, zeroNonce[:], m, emails)
+		require.Equal(sealed[sz:], aead.SearchToken(m, emails), "SearchToken(): %d", sz)
+	}
+}
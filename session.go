@@ -0,0 +1,167 @@
+// session.go - HS1-SIV incremental additional data
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrSessionFinished is the error thrown via a panic when a Session is used
+// after the message has been sealed or opened.
+var ErrSessionFinished = errors.New("hs1siv: session is finished")
+
+// Session seals or opens a single message, with the additional data
+// supplied incrementally (eg: one header field at a time), avoiding the
+// need to concatenate it into a single slice.
+//
+// Only the additional data can be supplied incrementally. The SIV is
+// derived from the entire message, and the message must be authenticated
+// before any of it is released, so the plaintext (or ciphertext) is still
+// passed in its entirety to SealRemaining (or OpenRemaining).
+//
+// The output is identical to that of Seal (or Open) with the concatenation
+// of every UpdateAD call as the additional data. A Session is not safe for
+// concurrent use, and is finished after a single SealRemaining or
+// OpenRemaining call.
+type Session struct {
+	ks     keySchedule
+	ctx    aeadCtx
+	keyCtx aeadCtx
+	nonce  [NonceSize]byte
+
+	nb       nhBuffer
+	aBytes   uint64
+	finished bool
+}
+
+// NewSession returns a new Session for a single message sealed or opened
+// with the provided nonce, or ErrInvalidNonceSize if the nonce is an
+// invalid size.
+//
+// The session has its own copy of the expanded key, and is not affected by
+// Wipe.
+func (ae *AEAD) NewSession(nonce []byte) (*Session, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	s := new(Session)
+	ae.rLock()
+	cached, err := ae.schedule()
+	if err == nil {
+		s.ks = *cached
+	}
+	s.ctx = ae.newCtx(&s.ks)
+	s.keyCtx = ae.newCtx(&s.ks)
+	ae.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	copy(s.nonce[:], nonce)
+	s.ctx.sivSetup(0, 0)
+	return s, nil
+}
+
+// UpdateAD appends ad to the additional data.
+func (s *Session) UpdateAD(ad []byte) {
+	if s.finished {
+		panic(ErrSessionFinished)
+	}
+	t := statsStart()
+	s.aBytes += uint64(len(ad))
+	s.nb.write(&s.ctx, ad)
+	statsHash(t, len(ad))
+}
+
+// SealRemaining encrypts and authenticates plaintext and the accumulated
+// additional data, and appends the result to dst, returning the updated
+// slice, as with Seal.
+//
+// The plaintext and dst must overlap exactly or not at all.
+func (s *Session) SealRemaining(dst, plaintext []byte) []byte {
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
+	t := statsStart()
+	s.finishAD(len(plaintext))
+	defer s.wipe()
+
+	mBytes := len(plaintext)
+	ret, out := sliceForAppend(dst, mBytes+s.ks.p.SIVLen)
+	s.ctx.sealHashed(t, 0, [][]byte{plaintext}, s.nonce[:], out[:mBytes], out[mBytes:])
+	return ret
+}
+
+// OpenRemaining decrypts and authenticates ciphertext and the accumulated
+// additional data, and if successful, appends the resulting plaintext to
+// dst, returning the updated slice, as with Open.
+//
+// The ciphertext and dst must overlap exactly or not at all.
+func (s *Session) OpenRemaining(dst, ciphertext []byte) ([]byte, error) {
+	if s.finished {
+		panic(ErrSessionFinished)
+	}
+	sivLen := s.ks.p.SIVLen
+	if len(ciphertext) < sivLen || checkPlaintextSize(uint64(len(ciphertext)-sivLen)) != nil {
+		s.finishAD(0)
+		s.wipe()
+		return nil, ErrOpen
+	}
+	mBytes := len(ciphertext) - sivLen
+	s.finishAD(mBytes)
+	defer s.wipe()
+
+	var sivBuf, maybeSIVBuf [hs1SIVLen]byte
+	siv, maybeSIV := sivBuf[:sivLen], maybeSIVBuf[:sivLen]
+	copy(siv, ciphertext[mBytes:]) // Work with a copy, `m` and `c` may alias.
+
+	// The additional data is already hashed, so the message key is
+	// derived with keyCtx, leaving the hash state in ctx intact.
+	ret, out := sliceForAppend(dst, mBytes)
+	t := statsStart()
+	var chachaKey [chacha20KeySize]byte
+	s.keyCtx.streamKey(siv, chachaKey[:])
+	t = statsCipher(t, 0)
+	s.ctx.openKeyed(t, chachaKey[:], ciphertext[:mBytes], s.nonce[:], out, maybeSIV)
+
+	ok := subtle.ConstantTimeCompare(siv, maybeSIV) == 1
+	memwipe(sivBuf[:])
+	memwipe(maybeSIVBuf[:])
+	if !ok {
+		// On decryption failures, purge the invalid plaintext.
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
+
+// finishAD hashes the final partial block of additional data, and sets the
+// lengths for a message of mBytes bytes.
+func (s *Session) finishAD(mBytes int) {
+	if s.finished {
+		panic(ErrSessionFinished)
+	}
+	s.finished = true
+
+	s.nb.flushPadded(&s.ctx)
+	binary.LittleEndian.PutUint64(s.ctx.sivLenBuf[0:8], s.aBytes)
+	binary.LittleEndian.PutUint64(s.ctx.sivLenBuf[8:16], uint64(mBytes))
+}
+
+func (s *Session) wipe() {
+	s.nb.reset()
+	s.ks = keySchedule{}
+}
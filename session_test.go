@@ -0,0 +1,116 @@
+// session_test.go - HS1-SIV incremental additional data tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	newHash := func(hashKey []byte) UniversalHash {
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	}
+
+	var ad [300]byte
+	_, _ = rand.Read(ad[:])
+	var m [200]byte
+	_, _ = rand.Read(m[:])
+
+	for name, aead := range map[string]*AEAD{
+		"New":                  New(key[:]),
+		"NewMed":               NewMed(key[:]),
+		"NewWithUniversalHash": NewWithUniversalHash(key[:], newHash),
+	} {
+		for _, adLen := range []int{0, 1, 15, 16, 63, 64, 65, 128, 300} {
+			for _, mLen := range []int{0, 1, 63, 64, 65, 200} {
+				expected := aead.Seal(nil, nonce[:], m[:mLen], ad[:adLen])
+
+				// Split the AD into irregularly sized pieces, including
+				// empty ones.
+				update := func(s *Session) {
+					a := ad[:adLen]
+					for i := 0; len(a) > 0; i++ {
+						n := (i*7 + 3) % 37
+						if n > len(a) {
+							n = len(a)
+						}
+						s.UpdateAD(a[:n])
+						a = a[n:]
+					}
+				}
+
+				s, err := aead.NewSession(nonce[:])
+				require.NoError(err, "%s: NewSession()", name)
+				update(s)
+				c := s.SealRemaining(nil, m[:mLen])
+				require.Equal(expected, c, "%s: SealRemaining(): ad %d, m %d", name, adLen, mLen)
+				require.Panics(func() { s.SealRemaining(nil, m[:mLen]) }, "%s: SealRemaining() twice", name)
+				require.Panics(func() { s.UpdateAD(nil) }, "%s: UpdateAD() after SealRemaining()", name)
+
+				s, err = aead.NewSession(nonce[:])
+				require.NoError(err, "%s: NewSession()", name)
+				update(s)
+				p, err := s.OpenRemaining(nil, c)
+				require.NoError(err, "%s: OpenRemaining(): ad %d, m %d", name, adLen, mLen)
+				require.NotNil(p, "%s: OpenRemaining(): ad %d, m %d", name, adLen, mLen)
+				require.Equal(m[:mLen], p, "%s: OpenRemaining(): ad %d, m %d", name, adLen, mLen)
+				require.PanicsWithValue(ErrSessionFinished, func() { _, _ = s.OpenRemaining(nil, c) }, "%s: OpenRemaining() twice", name)
+
+				// In place.
+				s, _ = aead.NewSession(nonce[:])
+				update(s)
+				buf := append([]byte{}, m[:mLen]...)
+				c = s.SealRemaining(buf[:0], buf)
+				require.Equal(expected, c, "%s: SealRemaining(in place): ad %d, m %d", name, adLen, mLen)
+				s, _ = aead.NewSession(nonce[:])
+				update(s)
+				p, err = s.OpenRemaining(c[:0], c)
+				require.NoError(err, "%s: OpenRemaining(in place): ad %d, m %d", name, adLen, mLen)
+				require.Equal(m[:mLen], p, "%s: OpenRemaining(in place): ad %d, m %d", name, adLen, mLen)
+
+				// Missing a byte of AD.
+				if adLen > 0 {
+					s, _ = aead.NewSession(nonce[:])
+					s.UpdateAD(ad[:adLen-1])
+					p, err = s.OpenRemaining(nil, expected)
+					require.Equal(ErrOpen, err, "%s: OpenRemaining(short ad): ad %d, m %d", name, adLen, mLen)
+					require.Nil(p, "%s: OpenRemaining(short ad): ad %d, m %d", name, adLen, mLen)
+				}
+			}
+		}
+	}
+
+	aead := New(key[:])
+	s, err := aead.NewSession(nonce[1:])
+	require.Equal(ErrInvalidNonceSize, err, "NewSession(short nonce)")
+	require.Nil(s, "NewSession(short nonce)")
+
+	s, _ = aead.NewSession(nonce[:])
+	p, err := s.OpenRemaining(nil, make([]byte, TagSize-1))
+	require.Equal(ErrOpen, err, "OpenRemaining(short ciphertext)")
+	require.Nil(p, "OpenRemaining(short ciphertext)")
+
+	// The session is unaffected by Wipe.
+	s, _ = aead.NewSession(nonce[:])
+	expected := aead.Seal(nil, nonce[:], m[:], ad[:])
+	aead.Wipe()
+	s.UpdateAD(ad[:])
+	require.Equal(expected, s.SealRemaining(nil, m[:]), "SealRemaining() after Wipe()")
+}
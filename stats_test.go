@@ -0,0 +1,94 @@
+// stats_test.go - HS1-SIV stage instrumentation tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := make([]byte, 4096+17)
+	ad := make([]byte, 100)
+
+	// Nothing is recorded while disabled.
+	ResetStats()
+	c := aead.Seal(nil, nonce[:], m, ad)
+	require.Equal(StageStats{}, Stats(), "Stats(): disabled")
+
+	EnableStats(true)
+	defer EnableStats(false)
+
+	session := func() *Session {
+		s, err := aead.NewSession(nonce[:])
+		require.NoError(err, "NewSession()")
+		s.UpdateAD(ad[:10])
+		s.UpdateAD(ad[10:])
+		return s
+	}
+	for _, v := range []struct {
+		name   string
+		passes int
+		fn     func()
+	}{
+		{"Seal", 1, func() { aead.Seal(nil, nonce[:], m, ad) }},
+		{"Open", 1, func() { _, _ = aead.Open(nil, nonce[:], c, ad) }},
+		{"OpenFused", 1, func() { _, _ = aead.OpenFused(nil, nonce[:], c, ad) }},
+		{"SealVectored", 1, func() { aead.SealVectored(nil, nonce[:], [][]byte{m[:17], m[17:]}, ad) }},
+		{"SealVectoredAD", 1, func() { aead.SealVectoredAD(nil, nonce[:], m, [][]byte{ad[:10], ad[10:]}) }},
+		{"OpenVectoredAD", 1, func() { _, _ = aead.OpenVectoredAD(nil, nonce[:], c, [][]byte{ad[:10], ad[10:]}) }},
+		{"SealRemaining", 1, func() { session().SealRemaining(nil, m) }},
+		{"OpenRemaining", 1, func() { _, _ = session().OpenRemaining(nil, c) }},
+		{"SealReader", 1, func() {
+			r, err := aead.SealReader(nonce[:], m, ad)
+			require.NoError(err, "SealReader()")
+			_, _ = io.Copy(io.Discard, r)
+		}},
+		{"SealSeekable", 1, func() { _ = aead.SealSeekable(io.Discard, bytes.NewReader(m), nonce[:], ad) }},
+		// The ciphertext is decrypted once to authenticate it, and again
+		// to write it out.
+		{"OpenToWriter", 2, func() { _, _ = aead.OpenToWriter(io.Discard, nonce[:], c, ad) }},
+	} {
+		ResetStats()
+		v.fn()
+		s := Stats()
+		require.EqualValues(len(m)+len(ad), s.HashBytes, "%s: HashBytes", v.name)
+		require.EqualValues(v.passes*len(m), s.CipherBytes, "%s: CipherBytes", v.name)
+		require.NotZero(s.HashNanos, "%s: HashNanos", v.name)
+		require.NotZero(s.CipherNanos, "%s: CipherNanos", v.name)
+	}
+
+	ResetStats()
+	require.Equal(StageStats{}, Stats(), "ResetStats()")
+}
+
+func BenchmarkStats(b *testing.B) {
+	for _, enabled := range []bool{false, true} {
+		name := "Disabled"
+		if enabled {
+			name = "Enabled"
+		}
+		b.Run(name, func(b *testing.B) {
+			EnableStats(enabled)
+			defer EnableStats(false)
+			doBenchmarkAEADEncrypt(b, 64)
+		})
+	}
+}
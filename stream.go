@@ -0,0 +1,249 @@
+// stream.go - HS1-SIV STREAM chunked encryption
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// StreamBaseNonceSize is the size of a STREAM base nonce in bytes.
+const StreamBaseNonceSize = NonceSize - 5
+
+// maxStreamChunks is the number of chunks that can be sealed before the
+// chunk counter wraps.
+const maxStreamChunks = 1 << 32
+
+var (
+	// ErrInvalidChunkSize is the error returned when a STREAM chunk size is
+	// invalid.
+	ErrInvalidChunkSize = errors.New("hs1siv: invalid chunk size")
+
+	// ErrStreamTooLong is the error returned when a STREAM would exceed
+	// the maximum number of chunks.
+	ErrStreamTooLong = errors.New("hs1siv: stream too long")
+
+	errStreamClosed = errors.New("hs1siv: stream is closed")
+)
+
+// NewEncryptStream returns an io.WriteCloser that encrypts and
+// authenticates everything written to it with the STREAM construction of
+// Hoang, Reyhanitabar, Rogaway and Vizár, writing the result to w.
+//
+// The plaintext is split into chunks of chunkSize bytes, each of which is
+// sealed independently, so neither side ever needs more than a chunk in
+// memory. The nonce for each chunk is base nonce || LE32(counter) ||
+// last, where last is 1 for the final chunk and 0 otherwise, so chunks can
+// not be reordered, and truncating the stream at a chunk boundary is
+// detected by NewDecryptStream.
+//
+// Close MUST be called to seal the final chunk, and does not close w. The
+// base nonce MUST be unique per stream.
+func NewEncryptStream(w io.Writer, key, baseNonce []byte, chunkSize int) (io.WriteCloser, error) {
+	ae, err := newStreamAEAD(key, baseNonce, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &encryptStream{
+		w:    w,
+		ae:   ae,
+		buf:  make([]byte, 0, chunkSize),
+		out:  make([]byte, 0, chunkSize+ae.Overhead()),
+		size: chunkSize,
+	}
+	copy(s.baseNonce[:], baseNonce)
+	return s, nil
+}
+
+// NewDecryptStream returns an io.Reader that decrypts and authenticates a
+// stream produced by NewEncryptStream with the same key, base nonce, and
+// chunk size, reading it from r.
+//
+// Each chunk is authenticated before any of its plaintext is returned. The
+// reader returns io.EOF only after the final chunk has been authenticated,
+// and ErrOpen if any chunk fails to authenticate, including if the stream
+// has been truncated or extended.
+func NewDecryptStream(r io.Reader, key, baseNonce []byte, chunkSize int) (io.Reader, error) {
+	ae, err := newStreamAEAD(key, baseNonce, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &decryptStream{
+		r:   r,
+		ae:  ae,
+		buf: make([]byte, chunkSize+ae.Overhead()+1),
+		out: make([]byte, 0, chunkSize),
+	}
+	copy(s.baseNonce[:], baseNonce)
+	return s, nil
+}
+
+func newStreamAEAD(key, baseNonce []byte, chunkSize int) (*AEAD, error) {
+	if len(baseNonce) != StreamBaseNonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	if chunkSize <= 0 || uint64(chunkSize) > MaxPlaintextSize {
+		return nil, ErrInvalidChunkSize
+	}
+	return NewWithError(key)
+}
+
+func streamNonce(nonce *[NonceSize]byte, baseNonce *[StreamBaseNonceSize]byte, counter uint64, last bool) {
+	copy(nonce[:], baseNonce[:])
+	binary.LittleEndian.PutUint32(nonce[StreamBaseNonceSize:], uint32(counter))
+	nonce[NonceSize-1] = 0
+	if last {
+		nonce[NonceSize-1] = 1
+	}
+}
+
+type encryptStream struct {
+	w         io.Writer
+	ae        *AEAD
+	baseNonce [StreamBaseNonceSize]byte
+	counter   uint64
+
+	buf  []byte
+	out  []byte
+	size int
+	err  error
+
+	// framed, if set, prefixes each sealed chunk with a frame header.
+	framed bool
+}
+
+func (s *encryptStream) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	var written int
+	for len(p) > 0 {
+		// The final chunk is only known to be final once Close is called,
+		// so a full chunk is only sealed once there is more data.
+		if len(s.buf) == s.size {
+			if s.err = s.sealChunk(false); s.err != nil {
+				return written, s.err
+			}
+		}
+		n := copy(s.buf[len(s.buf):s.size], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *encryptStream) Close() error {
+	if s.err != nil {
+		if s.err == errStreamClosed {
+			return nil
+		}
+		return s.err
+	}
+	err := s.sealChunk(true)
+	memwipe(s.buf[:cap(s.buf)])
+	s.ae.Wipe()
+	s.err = errStreamClosed
+	return err
+}
+
+func (s *encryptStream) sealChunk(last bool) error {
+	if s.counter == maxStreamChunks {
+		return ErrStreamTooLong
+	}
+
+	var nonce [NonceSize]byte
+	streamNonce(&nonce, &s.baseNonce, s.counter, last)
+	if s.framed {
+		s.out = s.ae.Seal(s.out[:frameHeaderSize], nonce[:], s.buf, nil)
+		putFrameHeader(s.out, len(s.out)-frameHeaderSize, last)
+	} else {
+		s.out = s.ae.Seal(s.out[:0], nonce[:], s.buf, nil)
+	}
+	s.counter++
+	s.buf = s.buf[:0]
+
+	_, err := s.w.Write(s.out)
+	return err
+}
+
+type decryptStream struct {
+	r         io.Reader
+	ae        *AEAD
+	baseNonce [StreamBaseNonceSize]byte
+	counter   uint64
+
+	// buf holds a sealed chunk, and one byte of lookahead (the first byte
+	// of the next chunk, if any) used to tell if the chunk is the last.
+	buf     []byte
+	partial int
+
+	out []byte
+	off int
+	err error
+}
+
+func (s *decryptStream) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for s.off == len(s.out) {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.err = s.openChunk(); s.err != nil {
+			s.ae.Wipe()
+		}
+	}
+
+	n := copy(p, s.out[s.off:])
+	s.off += n
+	return n, nil
+}
+
+func (s *decryptStream) openChunk() error {
+	sealedLen := len(s.buf) - 1
+	n, err := io.ReadFull(s.r, s.buf[s.partial:])
+	n += s.partial
+	var last bool
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	if s.counter == maxStreamChunks {
+		return ErrStreamTooLong
+	}
+
+	var nonce [NonceSize]byte
+	streamNonce(&nonce, &s.baseNonce, s.counter, last)
+	sealed := s.buf[:n]
+	if !last {
+		sealed = s.buf[:sealedLen]
+	}
+	s.out, err = s.ae.Open(s.out[:0], nonce[:], sealed, nil)
+	s.off = 0
+	if err != nil {
+		s.out = s.out[:0]
+		return err
+	}
+	s.counter++
+
+	if last {
+		return io.EOF
+	}
+	s.buf[0] = s.buf[sealedLen]
+	s.partial = 1
+	return nil
+}
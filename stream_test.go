@@ -0,0 +1,141 @@
+// stream_test.go - HS1-SIV STREAM chunked encryption tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var baseNonce [StreamBaseNonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(baseNonce[:])
+
+	encrypt := func(m []byte, chunkSize int) []byte {
+		var b bytes.Buffer
+		w, err := NewEncryptStream(&b, key[:], baseNonce[:], chunkSize)
+		require.NoError(err, "NewEncryptStream()")
+		for off := 0; off < len(m); {
+			n := 1 + off%23
+			if n > len(m)-off {
+				n = len(m) - off
+			}
+			written, err := w.Write(m[off : off+n])
+			require.NoError(err, "Write()")
+			require.Equal(n, written, "Write()")
+			off += n
+		}
+		require.NoError(w.Close(), "Close()")
+		require.NoError(w.Close(), "Close() twice")
+		_, err = w.Write([]byte{0})
+		require.Error(err, "Write() after Close()")
+		return b.Bytes()
+	}
+	decrypt := func(c []byte, chunkSize int) ([]byte, error) {
+		r, err := NewDecryptStream(iotest.OneByteReader(bytes.NewReader(c)), key[:], baseNonce[:], chunkSize)
+		require.NoError(err, "NewDecryptStream()")
+		return io.ReadAll(r)
+	}
+
+	var m [1000]byte
+	_, _ = rand.Read(m[:])
+	aead := New(key[:])
+	for _, chunkSize := range []int{1, 64, 100, 1000, 4096} {
+		for _, sz := range []int{0, 1, 63, 64, 99, 100, 101, 200, 1000} {
+			c := encrypt(m[:sz], chunkSize)
+			nChunks := 1 + sz/chunkSize
+			if sz > 0 && sz%chunkSize == 0 {
+				nChunks--
+			}
+			require.Len(c, sz+nChunks*TagSize, "chunk %d, size %d", chunkSize, sz)
+
+			// Each chunk is a regular HS1-SIV ciphertext.
+			var nonce [NonceSize]byte
+			streamNonce(&nonce, &baseNonce, uint64(nChunks-1), true)
+			last := c[(nChunks-1)*(chunkSize+TagSize):]
+			p, err := aead.Open(nil, nonce[:], last, nil)
+			require.NoError(err, "Open(last chunk): chunk %d, size %d", chunkSize, sz)
+			require.Equal(m[(nChunks-1)*chunkSize:sz], p, "Open(last chunk): chunk %d, size %d", chunkSize, sz)
+
+			p, err = decrypt(c, chunkSize)
+			require.NoError(err, "decrypt: chunk %d, size %d", chunkSize, sz)
+			require.Equal(m[:sz], p, "decrypt: chunk %d, size %d", chunkSize, sz)
+
+			// Truncated or extended by a byte.
+			_, err = decrypt(c[:len(c)-1], chunkSize)
+			require.Equal(ErrOpen, err, "decrypt(truncated): chunk %d, size %d", chunkSize, sz)
+			_, err = decrypt(append(append([]byte{}, c...), 0), chunkSize)
+			require.Equal(ErrOpen, err, "decrypt(extended): chunk %d, size %d", chunkSize, sz)
+
+			if nChunks > 1 {
+				// Truncated at a chunk boundary.
+				_, err = decrypt(c[:(nChunks-1)*(chunkSize+TagSize)], chunkSize)
+				require.Equal(ErrOpen, err, "decrypt(dropped chunk): chunk %d, size %d", chunkSize, sz)
+
+				// The first two chunks swapped.
+				if len(c) >= 2*(chunkSize+TagSize) {
+					swapped := append([]byte{}, c...)
+					copy(swapped, c[chunkSize+TagSize:2*(chunkSize+TagSize)])
+					copy(swapped[chunkSize+TagSize:], c[:chunkSize+TagSize])
+					_, err = decrypt(swapped, chunkSize)
+					require.Equal(ErrOpen, err, "decrypt(swapped): chunk %d, size %d", chunkSize, sz)
+				}
+			}
+		}
+	}
+
+	// The plaintext preceding a failed chunk is still returned.
+	c := encrypt(m[:300], 100)
+	c[len(c)-1] ^= 1
+	r, err := NewDecryptStream(bytes.NewReader(c), key[:], baseNonce[:], 100)
+	require.NoError(err, "NewDecryptStream()")
+	p, err := io.ReadAll(r)
+	require.Equal(ErrOpen, err, "decrypt(tampered)")
+	require.Equal(m[:200], p, "decrypt(tampered)")
+	_, err = r.Read(make([]byte, 1))
+	require.Equal(ErrOpen, err, "Read() after failure")
+
+	// An empty input is not a valid stream.
+	_, err = decrypt(nil, 100)
+	require.Equal(ErrOpen, err, "decrypt(empty)")
+}
+
+func TestStreamInvalid(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var baseNonce [StreamBaseNonceSize]byte
+	var b bytes.Buffer
+
+	for _, chunkSize := range []int{-1, 0} {
+		w, err := NewEncryptStream(&b, key[:], baseNonce[:], chunkSize)
+		require.Equal(ErrInvalidChunkSize, err, "NewEncryptStream(%d)", chunkSize)
+		require.Nil(w, "NewEncryptStream(%d)", chunkSize)
+		r, err := NewDecryptStream(&b, key[:], baseNonce[:], chunkSize)
+		require.Equal(ErrInvalidChunkSize, err, "NewDecryptStream(%d)", chunkSize)
+		require.Nil(r, "NewDecryptStream(%d)", chunkSize)
+	}
+
+	_, err := NewEncryptStream(&b, key[:], baseNonce[1:], 64)
+	require.Equal(ErrInvalidNonceSize, err, "NewEncryptStream(short nonce)")
+	_, err = NewDecryptStream(&b, key[:], make([]byte, NonceSize), 64)
+	require.Equal(ErrInvalidNonceSize, err, "NewDecryptStream(long nonce)")
+	_, err = NewEncryptStream(&b, nil, baseNonce[:], 64)
+	require.Equal(ErrInvalidKeySize, err, "NewEncryptStream(no key)")
+	_, err = NewDecryptStream(&b, make([]byte, KeySize+1), baseNonce[:], 64)
+	require.Equal(ErrInvalidKeySize, err, "NewDecryptStream(long key)")
+}
@@ -0,0 +1,74 @@
+// tag.go - HS1-SIV tag helpers
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import "bytes"
+
+// SplitTag splits the output of Seal into the ciphertext and the
+// authentication tag. The returned slices alias sealed.
+func (ae *AEAD) SplitTag(sealed []byte) (ciphertext, tag []byte, err error) {
+	tagSize := ae.Overhead()
+	if len(sealed) < tagSize {
+		return nil, nil, ErrInvalidCiphertextSize
+	}
+	mBytes := len(sealed) - tagSize
+	return sealed[:mBytes:mBytes], sealed[mBytes:], nil
+}
+
+// JoinTag combines a ciphertext and a detached authentication tag into a
+// newly allocated slice suitable for passing to Open.
+func (ae *AEAD) JoinTag(ciphertext, tag []byte) ([]byte, error) {
+	if len(tag) != ae.Overhead() {
+		return nil, ErrInvalidTagSize
+	}
+	sealed := make([]byte, 0, len(ciphertext)+len(tag))
+	sealed = append(sealed, ciphertext...)
+	return append(sealed, tag...), nil
+}
+
+// ValidateStructure checks that sealed is structurally plausible as the
+// output of Seal with the default parameter set, and returns the length of
+// the plaintext it would decrypt to.
+//
+// This does not require the key, and does not authenticate anything.
+func ValidateStructure(sealed []byte) (plaintextLen int, err error) {
+	if len(sealed) < TagSize {
+		return 0, ErrInvalidCiphertextSize
+	}
+	plaintextLen = len(sealed) - TagSize
+	if uint64(plaintextLen) > MaxPlaintextSize {
+		return 0, ErrInvalidCiphertextSize
+	}
+	return plaintextLen, nil
+}
+
+// IsLikelySealed returns true iff blob is structurally plausible as the
+// output of Seal for a plaintext of at least minPlaintext bytes, using the
+// same length reasoning as ValidateStructure.
+//
+// This is a heuristic for cheaply skipping blobs that can not possibly be
+// valid before attempting Open, and does not authenticate anything. Random
+// data of a plausible length will always be reported as likely sealed.
+func IsLikelySealed(blob []byte, minPlaintext int) bool {
+	plaintextLen, err := ValidateStructure(blob)
+	if err != nil {
+		return false
+	}
+	return minPlaintext <= 0 || plaintextLen >= minPlaintext
+}
+
+// FastSIVEqual returns true iff the two SIVs (tags) are equal, in variable
+// time. It is intended for content addressing and deduplication, where the
+// SIVs being compared are already public.
+//
+// FastSIVEqual MUST NOT be used to authenticate anything, as the timing
+// leaks how much of a forged tag is correct. Use Open, which does the
+// comparison in constant time.
+func FastSIVEqual(a, b []byte) bool {
+	return bytes.Equal(a, b)
+}
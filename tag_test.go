@@ -0,0 +1,105 @@
+// tag_test.go - HS1-SIV tag helper tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitJoinTag(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	for _, sz := range []int{0, 1, 63, 64, 65, 1024} {
+		m := make([]byte, sz)
+		_, _ = rand.Read(m)
+
+		sealed := aead.Seal(nil, nonce[:], m, nil)
+		c, tag, err := aead.SplitTag(sealed)
+		require.NoError(err, "SplitTag(): %d", sz)
+		require.Len(c, sz, "SplitTag(): len(c) %d", sz)
+		require.Len(tag, TagSize, "SplitTag(): len(tag) %d", sz)
+		require.Equal(sealed[:sz], c, "SplitTag(): c %d", sz)
+		require.Equal(sealed[sz:], tag, "SplitTag(): tag %d", sz)
+
+		joined, err := aead.JoinTag(c, tag)
+		require.NoError(err, "JoinTag(): %d", sz)
+		require.Equal(sealed, joined, "JoinTag(): %d", sz)
+
+		// Appending to the split ciphertext must not clobber the tag.
+		_ = append(c, 0xa5)
+		require.Equal(joined[sz:], tag, "SplitTag(): append(c) %d", sz)
+	}
+
+	_, _, err := aead.SplitTag(make([]byte, TagSize-1))
+	require.Equal(ErrInvalidCiphertextSize, err, "SplitTag(short)")
+
+	_, err = aead.JoinTag(nil, make([]byte, TagSize-1))
+	require.Equal(ErrInvalidTagSize, err, "JoinTag(short tag)")
+	_, err = aead.JoinTag(nil, make([]byte, TagSize+1))
+	require.Equal(ErrInvalidTagSize, err, "JoinTag(long tag)")
+}
+
+func TestValidateStructure(t *testing.T) {
+	require := require.New(t)
+
+	for _, sz := range []int{0, 1, 1024} {
+		sealed := make([]byte, sz+TagSize)
+		n, err := ValidateStructure(sealed)
+		require.NoError(err, "ValidateStructure(): %d", sz)
+		require.Equal(sz, n, "ValidateStructure(): %d", sz)
+	}
+
+	for _, sz := range []int{0, 1, TagSize - 1} {
+		_, err := ValidateStructure(make([]byte, sz))
+		require.Equal(ErrInvalidCiphertextSize, err, "ValidateStructure(short): %d", sz)
+	}
+}
+
+func TestIsLikelySealed(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	sealed := aead.Seal(nil, nonce[:], []byte("migrate me"), nil)
+	require.True(IsLikelySealed(sealed, 0), "IsLikelySealed(sealed, 0)")
+	require.True(IsLikelySealed(sealed, 10), "IsLikelySealed(sealed, 10)")
+	require.False(IsLikelySealed(sealed, 11), "IsLikelySealed(sealed, 11)")
+	require.True(IsLikelySealed(make([]byte, TagSize), -1), "IsLikelySealed(tag only, -1)")
+	require.False(IsLikelySealed(make([]byte, TagSize-1), 0), "IsLikelySealed(short, 0)")
+	require.False(IsLikelySealed(nil, 0), "IsLikelySealed(nil, 0)")
+}
+
+func TestFastSIVEqual(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	m := []byte("deduplicate me")
+	_, tag1, _ := aead.SplitTag(aead.Seal(nil, nonce[:], m, nil))
+	_, tag2, _ := aead.SplitTag(aead.Seal(nil, nonce[:], m, nil))
+	_, tag3, _ := aead.SplitTag(aead.Seal(nil, nonce[:], m[1:], nil))
+
+	require.True(FastSIVEqual(tag1, tag2), "FastSIVEqual(same)")
+	require.False(FastSIVEqual(tag1, tag3), "FastSIVEqual(different)")
+	require.False(FastSIVEqual(tag1, tag2[1:]), "FastSIVEqual(truncated)")
+}
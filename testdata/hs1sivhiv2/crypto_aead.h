@@ -0,0 +1,25 @@
+/*
+ * crypto_aead.h - Minimal SUPERCOP crypto_aead.h replacement
+ *
+ * To the extent possible under law, Yawning Angel has waived all copyright
+ * and related or neighboring rights to the software, using the Creative
+ * Commons "CC0" public domain dedication. See LICENSE or
+ * <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+ */
+
+#ifndef crypto_aead_H
+#define crypto_aead_H
+
+int crypto_aead_encrypt(unsigned char *c, unsigned long long *clen,
+                        const unsigned char *m, unsigned long long mlen,
+                        const unsigned char *ad, unsigned long long adlen,
+                        const unsigned char *nsec, const unsigned char *npub,
+                        const unsigned char *k);
+
+int crypto_aead_decrypt(unsigned char *m, unsigned long long *mlen,
+                        unsigned char *nsec, const unsigned char *c,
+                        unsigned long long clen, const unsigned char *ad,
+                        unsigned long long adlen, const unsigned char *npub,
+                        const unsigned char *k);
+
+#endif /* crypto_aead_H */
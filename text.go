@@ -0,0 +1,73 @@
+// text.go - HS1-SIV text encoded output
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidEncoding is the error returned when a text encoded sealed
+// message can not be decoded.
+var ErrInvalidEncoding = errors.New("hs1siv: invalid encoding")
+
+// SealString seals plaintext, and returns nonce || ciphertext || tag encoded
+// with standard (padded) base64.
+func (ae *AEAD) SealString(nonce, plaintext, additionalData []byte) (string, error) {
+	b, err := ae.sealPrefixed(nonce, plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// OpenString decodes and opens the output of SealString.
+func (ae *AEAD) OpenString(s string, additionalData []byte) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return ae.openPrefixed(nil, b, additionalData)
+}
+
+// SealHex seals plaintext, and returns nonce || ciphertext || tag encoded
+// as lower case hexadecimal.
+func (ae *AEAD) SealHex(nonce, plaintext, additionalData []byte) (string, error) {
+	b, err := ae.sealPrefixed(nonce, plaintext, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// OpenHex decodes and opens the output of SealHex.
+func (ae *AEAD) OpenHex(s string, additionalData []byte) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return ae.openPrefixed(nil, b, additionalData)
+}
+
+func (ae *AEAD) sealPrefixed(nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+	b := make([]byte, 0, NonceSize+len(plaintext)+ae.Overhead())
+	b = append(b, nonce...)
+	return ae.Seal(b, nonce, plaintext, additionalData), nil
+}
+
+func (ae *AEAD) openPrefixed(dst, b, additionalData []byte) ([]byte, error) {
+	if len(b) < NonceSize+ae.Overhead() {
+		return nil, ErrInvalidCiphertextSize
+	}
+	return ae.Open(dst, b[:NonceSize], b[NonceSize:], additionalData)
+}
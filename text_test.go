@@ -0,0 +1,63 @@
+// text_test.go - HS1-SIV text encoded output tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealString(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := []byte("database password")
+	ad := []byte("config")
+	expected := aead.Seal(append([]byte{}, nonce[:]...), nonce[:], m, ad)
+
+	for _, v := range []struct {
+		name   string
+		seal   func([]byte, []byte, []byte) (string, error)
+		open   func(string, []byte) ([]byte, error)
+		encode func([]byte) string
+	}{
+		{"base64", aead.SealString, aead.OpenString, base64.StdEncoding.EncodeToString},
+		{"hex", aead.SealHex, aead.OpenHex, hex.EncodeToString},
+	} {
+		s, err := v.seal(nonce[:], m, ad)
+		require.NoError(err, "Seal(): %s", v.name)
+		require.Equal(v.encode(expected), s, "Seal(): %s", v.name)
+
+		pt, err := v.open(s, ad)
+		require.NoError(err, "Open(): %s", v.name)
+		require.Equal(m, pt, "Open(): %s", v.name)
+
+		_, err = v.open(s, nil)
+		require.Equal(ErrOpen, err, "Open(bad ad): %s", v.name)
+
+		_, err = v.open("!"+s, ad)
+		require.ErrorIs(err, ErrInvalidEncoding, "Open(malformed): %s", v.name)
+
+		_, err = v.open(v.encode(expected[:NonceSize+TagSize-1]), ad)
+		require.Equal(ErrInvalidCiphertextSize, err, "Open(short): %s", v.name)
+		_, err = v.open("", ad)
+		require.Equal(ErrInvalidCiphertextSize, err, "Open(empty): %s", v.name)
+
+		_, err = v.seal(nonce[1:], m, ad)
+		require.Equal(ErrInvalidNonceSize, err, "Seal(bad nonce): %s", v.name)
+	}
+}
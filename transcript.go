@@ -0,0 +1,111 @@
+// transcript.go - HS1-SIV transcript bound messages
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// Transcript is a running HS1 digest of the messages exchanged between two
+// peers, used to bind each message to every message that preceded it (eg:
+// a response to the request that it answers).
+//
+// Each peer maintains its own Transcript, created with the same key and
+// label. Seal and Open fold the current transcript digest into the
+// additional data, and on success advance the transcript over the sealed
+// message, so as long as both peers process the same messages in the same
+// order their transcripts stay identical. A message sealed against any
+// other transcript fails to open.
+//
+// A failed Open does not advance the transcript.
+type Transcript struct {
+	mu    sync.Mutex
+	ae    *AEAD
+	sched *Schedule
+	state [HashSize]byte
+}
+
+// NewTranscript returns a new Transcript, with the initial state derived
+// from the AEAD key and label.
+func (ae *AEAD) NewTranscript(label []byte) *Transcript {
+	ae.rLock()
+	var transcriptKey [KeySize]byte
+	err := deriveKey(ae.key, kdfPurposeTranscript, nil, &transcriptKey)
+	ae.mu.RUnlock()
+	if err != nil {
+		panic(err)
+	}
+
+	t := &Transcript{
+		ae:    ae,
+		sched: NewSchedule(transcriptKey[:]),
+	}
+	h := t.sched.New()
+	_, _ = h.Write(label)
+	h.Sum(t.state[:0])
+	return t
+}
+
+// Sum returns the current transcript digest.
+func (t *Transcript) Sum() [HashSize]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// Seal encrypts and authenticates plaintext as with the AEAD's Seal, bound
+// to the current transcript, and advances the transcript.
+func (t *Transcript) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The additional data is copied, as it may alias the plaintext.
+	ad := t.boundAD(additionalData)
+	ret := t.ae.Seal(dst, nonce, plaintext, ad)
+	t.next(ad[HashSize:], ret[len(dst):], &t.state)
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext as with the AEAD's Open, bound
+// to the current transcript, and advances the transcript iff the message
+// is authentic.
+func (t *Transcript) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The next state is computed up front, as Open may decrypt in place.
+	var next [HashSize]byte
+	t.next(additionalData, ciphertext, &next)
+
+	ret, err := t.ae.Open(dst, nonce, ciphertext, t.boundAD(additionalData))
+	if err != nil {
+		return nil, err
+	}
+	t.state = next
+	return ret, nil
+}
+
+func (t *Transcript) boundAD(additionalData []byte) []byte {
+	ad := make([]byte, 0, HashSize+len(additionalData))
+	ad = append(ad, t.state[:]...)
+	return append(ad, additionalData...)
+}
+
+// next computes the next state, H(state || LE64(len(ad)) || ad || sealed).
+func (t *Transcript) next(additionalData, sealed []byte, next *[HashSize]byte) {
+	var l [8]byte
+	binary.LittleEndian.PutUint64(l[:], uint64(len(additionalData)))
+
+	h := t.sched.New()
+	_, _ = h.Write(t.state[:])
+	_, _ = h.Write(l[:])
+	_, _ = h.Write(additionalData)
+	_, _ = h.Write(sealed)
+	h.Sum(next[:0])
+}
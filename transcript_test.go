@@ -0,0 +1,78 @@
+// transcript_test.go - HS1-SIV transcript bound message tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	aead := New(key[:])
+
+	label := []byte("rpc session")
+	client, server := aead.NewTranscript(label), aead.NewTranscript(label)
+	require.Equal(client.Sum(), server.Sum(), "initial state")
+	require.NotEqual(client.Sum(), aead.NewTranscript(nil).Sum(), "label")
+
+	// Request/response exchange.
+	req := client.Seal(nil, nonce[:], []byte("GET /a"), []byte("req"))
+	m, err := server.Open(nil, nonce[:], req, []byte("req"))
+	require.NoError(err, "server.Open(req)")
+	require.Equal([]byte("GET /a"), m, "server.Open(req)")
+
+	resp := server.Seal(nil, nonce[:], []byte("200 a"), nil)
+	m, err = client.Open(nil, nonce[:], resp, nil)
+	require.NoError(err, "client.Open(resp)")
+	require.Equal([]byte("200 a"), m, "client.Open(resp)")
+	require.Equal(client.Sum(), server.Sum(), "state after exchange")
+
+	// The transcript binds messages, not just the key, so a message that
+	// would open with the bare AEAD does not.
+	_, err = aead.Open(nil, nonce[:], req, []byte("req"))
+	require.Equal(ErrOpen, err, "Open(req)")
+
+	// A response sealed for a different request fails to open, and does
+	// not advance the transcript.
+	req2 := client.Seal(nil, nonce[:], []byte("GET /b"), nil)
+	_, err = server.Open(nil, nonce[:], req2, nil)
+	require.NoError(err, "server.Open(req2)")
+	resp2 := server.Seal(nil, nonce[:], []byte("200 b"), nil)
+
+	before := client.Sum()
+	_, err = client.Open(nil, nonce[:], resp, nil)
+	require.Equal(ErrOpen, err, "client.Open(replayed resp)")
+	require.Equal(before, client.Sum(), "state after failed Open")
+
+	m, err = client.Open(nil, nonce[:], resp2, nil)
+	require.NoError(err, "client.Open(resp2)")
+	require.Equal([]byte("200 b"), m, "client.Open(resp2)")
+
+	// Decrypting in place must still advance over the ciphertext, and the
+	// additional data is bound into the transcript.
+	req3 := client.Seal(nil, nonce[:], []byte("GET /c"), []byte("ad"))
+	_, err = server.Open(req3[:0], nonce[:], req3, []byte("ad"))
+	require.NoError(err, "server.Open(in place)")
+	require.Equal(client.Sum(), server.Sum(), "state after in place Open")
+
+	c1 := aead.NewTranscript(label).Seal(nil, nonce[:], nil, []byte("a"))
+	c2 := aead.NewTranscript(label).Seal(nil, nonce[:], nil, []byte("b"))
+	require.NotEqual(c1, c2, "ad bound")
+
+	// Different keys yield unrelated transcripts.
+	initial := aead.NewTranscript(label).Sum()
+	_, _ = rand.Read(key[:])
+	require.NotEqual(initial, New(key[:]).NewTranscript(label).Sum(), "key")
+}
@@ -0,0 +1,94 @@
+// universalhash.go - Pluggable universal hash backends
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// UniversalHashBlockSize is the granularity in bytes of the input passed to
+// UniversalHash.Step.
+const UniversalHashBlockSize = hs1NHLen
+
+// UniversalHash is a keyed universal hash function that can be used in place
+// of the HS1 hash when computing the SIV and the message encryption key.
+//
+// Instances are never shared across goroutines, and need not be safe for
+// concurrent use.
+type UniversalHash interface {
+	// Size returns the size of the hash output in bytes, which MUST be
+	// between 1 and KeySize inclusive.
+	Size() int
+
+	// Reset resets the hash to its initial state.
+	Reset()
+
+	// Step absorbs in, the length of which is always a multiple of
+	// UniversalHashBlockSize.
+	Step(in []byte)
+
+	// Finalize absorbs the final input in, the length of which is always
+	// between 16 and UniversalHashBlockSize bytes inclusive and a multiple
+	// of 16, and writes Size() bytes of output to out.
+	Finalize(in, out []byte)
+}
+
+// NewWithUniversalHash returns a new keyed HS1-SIV instance that uses the
+// universal hash returned by newHash in place of the HS1 hash. newHash is
+// called with a KeySize byte hash key derived from key, once per operation.
+// The key sizes accepted are the same as with New.
+//
+// WARNING: Ciphertexts sealed by the returned instance are not HS1-SIV, and
+// the security of the construction depends entirely on the properties of
+// the supplied hash.
+func NewWithUniversalHash(key []byte, newHash func(hashKey []byte) UniversalHash) *AEAD {
+	if !isValidKeySize(len(key)) {
+		panic(ErrInvalidKeySize)
+	}
+
+	ae := &AEAD{
+		key:     append([]byte{}, key...),
+		p:       &paramsHi,
+		newHash: newHash,
+	}
+	if err := deriveKey(key, kdfPurposeUniversalHash, nil, &ae.uhKey); err != nil {
+		panic(err)
+	}
+	if sz := newHash(ae.uhKey[:]).Size(); sz < 1 || sz > KeySize {
+		panic("hs1siv: invalid universal hash output size")
+	}
+	return ae.expandKey()
+}
+
+// uhAdapter wraps a UniversalHash, staging all input and output through
+// its own separately allocated buffers, so that neither the stack allocated
+// buffers nor the key schedule used by the rest of the implementation
+// escape to the heap via the interface calls.
+type uhAdapter struct {
+	h   UniversalHash
+	buf []byte
+	out []byte
+}
+
+func newUHAdapter(h UniversalHash) *uhAdapter {
+	return &uhAdapter{
+		h:   h,
+		buf: make([]byte, 16*UniversalHashBlockSize),
+		out: make([]byte, KeySize),
+	}
+}
+
+func (a *uhAdapter) step(in []byte) {
+	for len(in) > 0 {
+		n := copy(a.buf, in)
+		a.h.Step(a.buf[:n])
+		in = in[n:]
+	}
+}
+
+func (a *uhAdapter) finalize(in, out []byte) {
+	n := copy(a.buf, in)
+	a.h.Finalize(a.buf[:n], a.out)
+	copy(out, a.out[:a.h.Size()])
+}
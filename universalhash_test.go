@@ -0,0 +1,99 @@
+// universalhash_test.go - Pluggable universal hash backend tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"hash"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testHash is a trivial (and not actually universal) hash, that checks that
+// it is used as documented.
+type testHash struct {
+	t   *testing.T
+	key []byte
+	h   hash.Hash
+}
+
+func (h *testHash) Size() int {
+	return 16
+}
+
+func (h *testHash) Reset() {
+	h.h = sha256.New()
+	h.h.Write(h.key)
+}
+
+func (h *testHash) Step(in []byte) {
+	if len(in)%UniversalHashBlockSize != 0 {
+		h.t.Fatalf("Step(): invalid length %d", len(in))
+	}
+	h.h.Write(in)
+}
+
+func (h *testHash) Finalize(in, out []byte) {
+	if len(in) < 16 || len(in) > UniversalHashBlockSize || len(in)%16 != 0 {
+		h.t.Fatalf("Finalize(): invalid length %d", len(in))
+	}
+	h.h.Write(in)
+	copy(out, h.h.Sum(nil)[:h.Size()])
+}
+
+func TestUniversalHash(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	var hashKeys [][]byte
+	aead := NewWithUniversalHash(key[:], func(hashKey []byte) UniversalHash {
+		hashKeys = append(hashKeys, append([]byte{}, hashKey...))
+		h := &testHash{t: t, key: hashKey}
+		h.Reset()
+		return h
+	})
+	ref := New(key[:])
+
+	for _, sz := range []int{0, 1, 16, 63, 64, 65, 1000} {
+		m := make([]byte, sz)
+		ad := make([]byte, sz/2)
+		_, _ = rand.Read(m)
+		_, _ = rand.Read(ad)
+
+		c := aead.Seal(nil, nonce[:], m, ad)
+		require.NotEqual(ref.Seal(nil, nonce[:], m, ad), c, "Seal(%d) vs HS1", sz)
+
+		d, err := aead.Open(nil, nonce[:], c, ad)
+		require.NoError(err, "Open(%d)", sz)
+		require.True(bytes.Equal(m, d), "Open(%d)", sz)
+
+		d, err = aead.OpenFused(nil, nonce[:], c, ad)
+		require.NoError(err, "OpenFused(%d)", sz)
+		require.True(bytes.Equal(m, d), "OpenFused(%d)", sz)
+
+		_, err = ref.Open(nil, nonce[:], c, ad)
+		require.Equal(ErrOpen, err, "HS1 Open(%d)", sz)
+
+		c[len(c)-1] ^= 0x01
+		_, err = aead.Open(nil, nonce[:], c, ad)
+		require.Equal(ErrOpen, err, "Open(%d, bad tag)", sz)
+	}
+
+	// The hash key is derived from, and is distinct from the user key.
+	for _, k := range hashKeys {
+		require.Equal(hashKeys[0], k, "hash key")
+	}
+	require.False(bytes.Equal(key[:], hashKeys[0]), "hash key == user key")
+}
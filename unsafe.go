@@ -0,0 +1,82 @@
+// unsafe.go - HS1-SIV unauthenticated decryption
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// OpenUnsafe decrypts ciphertext, and returns the resulting plaintext in a
+// newly allocated slice regardless of whether or not authentication
+// succeeded, along with whether it did.
+//
+// WARNING: This is intended for forensic tooling operating on data that the
+// caller owns, and MUST NOT be used anywhere the authenticity of the
+// plaintext matters. If tagMatched is false, the plaintext is whatever the
+// corrupted (or forged) ciphertext happened to decrypt to. Use Open.
+func (ae *AEAD) OpenUnsafe(nonce, ciphertext, additionalData []byte) (plaintext []byte, tagMatched bool) {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if !ae.isValidCiphertextSize(len(ciphertext)) {
+		return nil, false
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return nil, false
+	}
+	ctx := ae.newCtx(ks)
+	plaintext = make([]byte, len(ciphertext)-ae.Overhead())
+	tagMatched = ctx.decrypt(ciphertext, additionalData, nonce, plaintext)
+	return plaintext, tagMatched
+}
+
+// DecryptRange decrypts ciphertext[offset:offset+length], and returns the
+// resulting plaintext in a newly allocated slice, given the message's SIV
+// (tag). The ciphertext may include the tag, but the range must only cover
+// the encrypted message.
+//
+// WARNING: This provides NO AUTHENTICATION WHATSOEVER. The range can be
+// decrypted without the rest of the message precisely because nothing is
+// verified, so a corrupted or forged ciphertext (or SIV) silently decrypts
+// to garbage. This is intended for random reads from storage that has its
+// own integrity protection, and MUST NOT be used otherwise. Use Open.
+func (ae *AEAD) DecryptRange(nonce, siv, ciphertext []byte, offset, length int) []byte {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if len(siv) != ae.Overhead() {
+		panic(ErrInvalidTagSize)
+	}
+	if offset < 0 || length < 0 || offset > len(ciphertext) || length > len(ciphertext)-offset {
+		panic("hs1siv: invalid decryption range")
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+
+	// The message is encrypted starting at block 1, so the block containing
+	// offset is 1 + offset/64, and the start of the range is offset%64
+	// bytes into it.
+	var chachaKey [chacha20KeySize]byte
+	ctx.streamKey(siv, chachaKey[:])
+	stream := mustNewChaCha(ks.p.ChaChaRounds, chachaKey[:], nonce, uint32(1+offset/chachaBlockSize))
+	memwipe(chachaKey[:])
+	var skip [chachaBlockSize]byte
+	stream.XORKeyStream(skip[:offset%chachaBlockSize], skip[:offset%chachaBlockSize])
+
+	plaintext := make([]byte, length)
+	stream.XORKeyStream(plaintext, ciphertext[offset:offset+length])
+	return plaintext
+}
@@ -0,0 +1,86 @@
+// unsafe_test.go - HS1-SIV unauthenticated decryption tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenUnsafe(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := []byte("forensically interesting plaintext")
+	ad := []byte("ad")
+	c := aead.Seal(nil, nonce[:], m, ad)
+
+	d, ok := aead.OpenUnsafe(nonce[:], c, ad)
+	require.True(ok, "OpenUnsafe(): tagMatched")
+	require.Equal(m, d, "OpenUnsafe()")
+
+	// Corrupting the ciphertext still yields the (corrupted) plaintext.
+	badC := append([]byte{}, c...)
+	badC[3] ^= 0x23
+	d, ok = aead.OpenUnsafe(nonce[:], badC, ad)
+	require.False(ok, "OpenUnsafe(bad c): tagMatched")
+	expected := append([]byte{}, m...)
+	expected[3] ^= 0x23
+	require.Equal(expected, d, "OpenUnsafe(bad c)")
+
+	// Corrupting the AD does not affect the plaintext.
+	d, ok = aead.OpenUnsafe(nonce[:], c, ad[1:])
+	require.False(ok, "OpenUnsafe(bad ad): tagMatched")
+	require.Equal(m, d, "OpenUnsafe(bad ad)")
+
+	d, ok = aead.OpenUnsafe(nonce[:], c[:TagSize-1], ad)
+	require.False(ok, "OpenUnsafe(short): tagMatched")
+	require.Nil(d, "OpenUnsafe(short)")
+}
+
+func TestDecryptRange(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+	aead := New(key[:])
+
+	m := make([]byte, 1000)
+	_, _ = rand.Read(m)
+	sealed := aead.Seal(nil, nonce[:], m, []byte("ad"))
+	c, siv, err := aead.SplitTag(sealed)
+	require.NoError(err, "SplitTag()")
+
+	for _, r := range [][2]int{
+		{0, 0}, {0, 1}, {0, 1000}, {1, 63}, {63, 2}, {64, 64}, {65, 200}, {999, 1}, {1000, 0},
+	} {
+		offset, length := r[0], r[1]
+		require.Equal(m[offset:offset+length], aead.DecryptRange(nonce[:], siv, c, offset, length), "DecryptRange(%d, %d)", offset, length)
+		require.Equal(m[offset:offset+length], aead.DecryptRange(nonce[:], siv, sealed, offset, length), "DecryptRange(sealed, %d, %d)", offset, length)
+	}
+
+	// Nothing is authenticated.
+	badSIV := append([]byte{}, siv...)
+	badSIV[0] ^= 1
+	require.NotEqual(m[:16], aead.DecryptRange(nonce[:], badSIV, c, 0, 16), "DecryptRange(bad siv)")
+
+	for _, r := range [][2]int{{-1, 1}, {0, -1}, {1001, 0}, {1000, 1}, {0, 1001}} {
+		require.Panics(func() { aead.DecryptRange(nonce[:], siv, c, r[0], r[1]) }, "DecryptRange(%d, %d)", r[0], r[1])
+	}
+	require.Panics(func() { aead.DecryptRange(nonce[1:], siv, c, 0, 1) }, "DecryptRange(bad nonce)")
+	require.Panics(func() { aead.DecryptRange(nonce[:], siv[1:], c, 0, 1) }, "DecryptRange(bad siv size)")
+}
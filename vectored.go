@@ -0,0 +1,103 @@
+// vectored.go - HS1-SIV vectored input
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+// SealVectored encrypts and authenticates the concatenation of plaintexts
+// as with Seal, without first copying the fragments into a single slice.
+// The output is identical to that of Seal with the concatenated plaintext.
+//
+// dst must not overlap any of the plaintext fragments.
+func (ae *AEAD) SealVectored(dst, nonce []byte, plaintexts [][]byte, additionalData []byte) []byte {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	var mBytes uint64
+	for _, p := range plaintexts {
+		mBytes += uint64(len(p))
+	}
+	if err := checkPlaintextSize(mBytes); err != nil {
+		panic(err)
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+	ret, out := sliceForAppend(dst, int(mBytes)+ae.p.SIVLen)
+	ctx.encryptVectored(plaintexts, [][]byte{additionalData}, nonce, out[:mBytes], out[mBytes:])
+	return ret
+}
+
+// SealVectoredAD encrypts and authenticates plaintext as with Seal, with
+// the additional data being the concatenation of additionalData, without
+// first copying the fragments into a single slice. The output is identical
+// to that of Seal with the concatenated additional data.
+//
+// The plaintext and dst must overlap exactly or not at all, and the
+// additional data may alias either.
+func (ae *AEAD) SealVectoredAD(dst, nonce, plaintext []byte, additionalData [][]byte) []byte {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	ks, err := ae.schedule()
+	if err != nil {
+		panic(err)
+	}
+	ctx := ae.newCtx(ks)
+	mBytes := len(plaintext)
+	ret, out := sliceForAppend(dst, mBytes+ae.p.SIVLen)
+	ctx.encryptVectored([][]byte{plaintext}, additionalData, nonce, out[:mBytes], out[mBytes:])
+	return ret
+}
+
+// OpenVectoredAD decrypts and authenticates ciphertext as with Open, with
+// the additional data being the concatenation of additionalData, as with
+// SealVectoredAD.
+//
+// The ciphertext and dst must overlap exactly or not at all.
+func (ae *AEAD) OpenVectoredAD(dst, nonce, ciphertext []byte, additionalData [][]byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		panic(ErrInvalidNonceSize)
+	}
+
+	ae.rLock()
+	defer ae.mu.RUnlock()
+
+	if !ae.isValidCiphertextSize(len(ciphertext)) {
+		return nil, ErrOpen
+	}
+
+	ks, err := ae.schedule()
+	if err != nil {
+		return nil, err
+	}
+	ctx := ae.newCtx(ks)
+	ret, out := sliceForAppend(dst, len(ciphertext)-ae.p.SIVLen)
+	if !ctx.decryptVectored(ciphertext, additionalData, nonce, out) {
+		// On decryption failures, purge the invalid plaintext.
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrOpen
+	}
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
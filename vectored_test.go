@@ -0,0 +1,116 @@
+// vectored_test.go - HS1-SIV vectored input tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// splitFragments splits b into fragments at arbitrary points, including
+// empty fragments and ones that straddle NH block boundaries.
+func splitFragments(b []byte, seed int) [][]byte {
+	var frags [][]byte
+	for i := 0; len(b) > 0; i++ {
+		n := (seed*7 + i*37) % 151
+		if n > len(b) {
+			n = len(b)
+		}
+		frags = append(frags, b[:n])
+		b = b[n:]
+	}
+	return append(frags, nil)
+}
+
+func TestSealVectored(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	var m [1100]byte
+	ad := []byte("vectored ad")
+	_, _ = rand.Read(m[:])
+
+	for _, aead := range []*AEAD{New(key[:]), NewMed(key[:])} {
+		for _, sz := range []int{0, 1, 63, 64, 65, 127, 128, 129, 1000, len(m)} {
+			expected := aead.Seal(nil, nonce[:], m[:sz], ad)
+			for seed := 0; seed < 5; seed++ {
+				frags := splitFragments(m[:sz], seed)
+				c := aead.SealVectored(nil, nonce[:], frags, ad)
+				require.Equal(expected, c, "SealVectored(): size %d, seed %d", sz, seed)
+			}
+
+			prefix := []byte("prefix")
+			c := aead.SealVectored(append([]byte{}, prefix...), nonce[:], [][]byte{m[:sz]}, ad)
+			require.Equal(append(prefix, expected...), c, "SealVectored(prefix): size %d", sz)
+		}
+	}
+
+	aead := New(key[:])
+	require.Equal(aead.Seal(nil, nonce[:], nil, ad), aead.SealVectored(nil, nonce[:], nil, ad), "SealVectored(nil)")
+	require.PanicsWithValue(ErrInvalidNonceSize, func() { aead.SealVectored(nil, nonce[1:], nil, nil) }, "SealVectored(short nonce)")
+}
+
+func TestVectoredAD(t *testing.T) {
+	require := require.New(t)
+
+	var key [KeySize]byte
+	var nonce [NonceSize]byte
+	_, _ = rand.Read(key[:])
+	_, _ = rand.Read(nonce[:])
+
+	var ad [1100]byte
+	m := []byte("vectored ad message")
+	_, _ = rand.Read(ad[:])
+
+	for _, aead := range []*AEAD{New(key[:]), NewMed(key[:])} {
+		for _, sz := range []int{0, 1, 63, 64, 65, 127, 128, 129, 1000, len(ad)} {
+			expected := aead.Seal(nil, nonce[:], m, ad[:sz])
+			for seed := 0; seed < 5; seed++ {
+				frags := splitFragments(ad[:sz], seed)
+				c := aead.SealVectoredAD(nil, nonce[:], m, frags)
+				require.Equal(expected, c, "SealVectoredAD(): size %d, seed %d", sz, seed)
+
+				p, err := aead.OpenVectoredAD(nil, nonce[:], c, frags)
+				require.NoError(err, "OpenVectoredAD(): size %d, seed %d", sz, seed)
+				require.Equal(m, p, "OpenVectoredAD(): size %d, seed %d", sz, seed)
+			}
+
+			// Every single fragment split is equivalent.
+			for i := 0; i <= sz; i += 1 + sz/17 {
+				c := aead.SealVectoredAD(nil, nonce[:], m, [][]byte{ad[:i], ad[i:sz]})
+				require.Equal(expected, c, "SealVectoredAD(): size %d, split %d", sz, i)
+			}
+
+			if sz > 0 {
+				badAD := append([]byte{}, ad[:sz]...)
+				badAD[sz-1] ^= 1
+				p, err := aead.OpenVectoredAD(nil, nonce[:], expected, splitFragments(badAD, 0))
+				require.Equal(ErrOpen, err, "OpenVectoredAD(bad ad): size %d", sz)
+				require.Nil(p, "OpenVectoredAD(bad ad): size %d", sz)
+			}
+		}
+	}
+
+	// Opening in place.
+	aead := New(key[:])
+	frags := [][]byte{ad[:10], ad[10:100]}
+	c := aead.SealVectoredAD(nil, nonce[:], m, frags)
+	p, err := aead.OpenVectoredAD(c[:0], nonce[:], c, frags)
+	require.NoError(err, "OpenVectoredAD(in place)")
+	require.Equal(m, p, "OpenVectoredAD(in place)")
+
+	_, err = aead.OpenVectoredAD(nil, nonce[:], make([]byte, TagSize-1), frags)
+	require.Equal(ErrOpen, err, "OpenVectoredAD(short)")
+	require.PanicsWithValue(ErrInvalidNonceSize, func() { aead.SealVectoredAD(nil, nonce[1:], m, nil) }, "SealVectoredAD(short nonce)")
+}
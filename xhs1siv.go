@@ -0,0 +1,143 @@
+// xhs1siv.go - XHS1-SIV (HS1-SIV with an extended nonce)
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"runtime"
+	"sync"
+)
+
+// NonceSizeX is the size of an XHS1-SIV nonce in bytes.
+const NonceSizeX = 24
+
+// AEADX is a XHS1-SIV instance, implementing crypto/cipher.AEAD. XHS1-SIV
+// is to HS1-SIV what XChaCha20-Poly1305 is to ChaCha20-Poly1305, and
+// accepts a NonceSizeX byte nonce, which is large enough to be safely
+// generated at random.
+//
+// For each message, a subkey is derived with HChaCha20 from the key and
+// the first 16 bytes of the nonce, and the message is sealed with
+// hs1-siv-hi under the subkey, with a nonce of 4 zero bytes followed by
+// the remaining 8 bytes of the nonce. As the subkey changes with every
+// nonce, each operation includes a full key expansion, and XHS1-SIV is
+// considerably slower than HS1-SIV for short messages.
+//
+// An AEADX is safe for concurrent use by multiple goroutines.
+type AEADX struct {
+	mu    sync.RWMutex
+	wiped bool
+
+	key [KeySize]byte
+}
+
+// NewX returns a new keyed XHS1-SIV instance. The key MUST be KeySize
+// bytes, and NewX panics with ErrInvalidKeySize otherwise.
+func NewX(key []byte) *AEADX {
+	if len(key) != KeySize {
+		panic(ErrInvalidKeySize)
+	}
+
+	ae := new(AEADX)
+	copy(ae.key[:], key)
+	runtime.SetFinalizer(ae, (*AEADX).Wipe)
+	return ae
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and
+// Open, which is NonceSizeX.
+func (ae *AEADX) NonceSize() int {
+	return NonceSizeX
+}
+
+// Overhead returns the maximum difference between the lengths of a plaintext
+// and its ciphertext, which is TagSize.
+func (ae *AEADX) Overhead() int {
+	return TagSize
+}
+
+// Seal encrypts and authenticates plaintext, authenticates the
+// additional data and appends the result to dst, returning the updated
+// slice, as with AEAD.Seal. The nonce must be NonceSizeX bytes long.
+func (ae *AEADX) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != NonceSizeX {
+		panic(ErrInvalidNonceSize)
+	}
+	if err := checkPlaintextSize(uint64(len(plaintext))); err != nil {
+		panic(err)
+	}
+
+	var ks keySchedule
+	var n [NonceSize]byte
+	if err := ae.subSchedule(nonce, &ks, &n); err != nil {
+		panic(err)
+	}
+	defer func() { ks = keySchedule{} }()
+
+	ctx := aeadCtx{keySchedule: &ks}
+	ret, out := sliceForAppend(dst, len(plaintext)+TagSize)
+	ctx.encrypt(plaintext, additionalData, n[:], out)
+	return ret
+}
+
+// Open decrypts and authenticates ciphertext, authenticates the
+// additional data and, if successful, appends the resulting plaintext
+// to dst, returning the updated slice, as with AEAD.Open. The nonce must
+// be NonceSizeX bytes long.
+func (ae *AEADX) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != NonceSizeX {
+		panic(ErrInvalidNonceSize)
+	}
+	if len(ciphertext) < TagSize || checkPlaintextSize(uint64(len(ciphertext)-TagSize)) != nil {
+		return nil, ErrOpen
+	}
+
+	var ks keySchedule
+	var n [NonceSize]byte
+	if err := ae.subSchedule(nonce, &ks, &n); err != nil {
+		return nil, err
+	}
+	defer func() { ks = keySchedule{} }()
+
+	ctx := aeadCtx{keySchedule: &ks}
+	ret, out := sliceForAppend(dst, len(ciphertext)-TagSize)
+	if !ctx.decrypt(ciphertext, additionalData, n[:], out) {
+		// On decryption failures, purge the invalid plaintext.
+		memwipe(out)
+		return nil, ErrOpen
+	}
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
+
+// Wipe zeroes the key held by the instance, as with AEAD.Wipe.
+func (ae *AEADX) Wipe() {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	memwipe(ae.key[:])
+	ae.wiped = true
+}
+
+// subSchedule derives the per-nonce subkey, expands it into ks, and sets n
+// to the HS1-SIV nonce.
+func (ae *AEADX) subSchedule(nonce []byte, ks *keySchedule, n *[NonceSize]byte) error {
+	ae.mu.RLock()
+	if ae.wiped {
+		ae.mu.RUnlock()
+		panic(ErrKeyReset)
+	}
+	var subKey [KeySize]byte
+	hChaCha20(ae.key[:], nonce[:16], &subKey)
+	ae.mu.RUnlock()
+	defer memwipe(subKey[:])
+
+	copy(n[4:], nonce[16:])
+	return ks.setup(subKey[:], &paramsHi)
+}
@@ -0,0 +1,106 @@
+// xhs1siv_test.go - XHS1-SIV tests
+//
+// To the extent possible under law, Yawning Angel has waived all copyright
+// and related or neighboring rights to the software, using the Creative
+// Commons "CC0" public domain dedication. See LICENSE or
+// <http://creativecommons.org/publicdomain/zero/1.0/> for full details.
+
+package hs1siv
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	rtChacha "golang.org/x/crypto/chacha20"
+)
+
+func TestHChaCha20(t *testing.T) {
+	require := require.New(t)
+
+	// draft-irtf-cfrg-xchacha-03 2.2.1.
+	key, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	nonce, _ := hex.DecodeString("000000090000004a0000000031415927")
+	expected, _ := hex.DecodeString("82413b4227b27bfed30e42508a877d73a0f9e4d58a74a853c12ec41326d3ecdc")
+
+	var subKey [chacha20KeySize]byte
+	hChaCha20(key, nonce, &subKey)
+	require.Equal(expected, subKey[:], "hChaCha20(): draft vector")
+
+	// Against x/crypto.
+	for i := 0; i < 100; i++ {
+		_, _ = rand.Read(key)
+		_, _ = rand.Read(nonce)
+		expected, err := rtChacha.HChaCha20(key, nonce)
+		require.NoError(err, "HChaCha20()")
+		hChaCha20(key, nonce, &subKey)
+		require.Equal(expected, subKey[:], "hChaCha20(): %d", i)
+	}
+}
+
+func TestXHS1SIV(t *testing.T) {
+	require := require.New(t)
+
+	// The "known good" values were generated by this implementation, with
+	// the TestKAT inputs (and the nonce pattern extended to NonceSizeX
+	// bytes), and are checked against HS1-SIV under the subkey derived by
+	// HChaCha20, which is checked against x/crypto by TestHChaCha20.
+	w, h, k, _ := katInputs()
+	var n [NonceSizeX]byte
+	for i := range n {
+		n[i] = byte(255 & (i*181 + 123))
+	}
+
+	aead := NewX(k[:])
+	require.Equal(NonceSizeX, aead.NonceSize(), "NonceSize()")
+	require.Equal(TagSize, aead.Overhead(), "Overhead()")
+
+	var subKey [KeySize]byte
+	hChaCha20(k[:], n[:16], &subKey)
+	var subNonce [NonceSize]byte
+	copy(subNonce[4:], n[16:])
+	sub := New(subKey[:])
+
+	katHash := sha256.New()
+	for i := range w {
+		c := aead.Seal(nil, n[:], w[:i], h[:i])
+		require.Len(c, i+TagSize, "Seal(): len(c) %d", i)
+		require.Equal(sub.Seal(nil, subNonce[:], w[:i], h[:i]), c, "Seal() vs subkey: %d", i)
+		if i == 0 {
+			require.Equal(katXEmpty, hex.EncodeToString(c), "Seal(): empty")
+		}
+		_, _ = katHash.Write(c)
+
+		m, err := aead.Open(nil, n[:], c, h[:i])
+		require.NoError(err, "Open(): %d", i)
+		require.Equal(w[:i], append([]byte{}, m...), "Open(): m %d", i)
+
+		badC := append([]byte{}, c...)
+		badC[i] ^= 0x23
+		m, err = aead.Open(nil, n[:], badC, h[:i])
+		require.Equal(ErrOpen, err, "Open(Bad c): %d", i)
+		require.Nil(m, "Open(Bad c): %d", i)
+	}
+	require.Equal(katXDigest, hex.EncodeToString(katHash.Sum(nil)), "Seal(): digest")
+
+	// Every part of the extended nonce matters.
+	c := aead.Seal(nil, n[:], w[:], nil)
+	for _, off := range []int{0, 15, 16, NonceSizeX - 1} {
+		badN := n
+		badN[off] ^= 1
+		_, err := aead.Open(nil, badN[:], c, nil)
+		require.Equal(ErrOpen, err, "Open(Bad n %d)", off)
+	}
+	_, err := aead.Open(nil, n[:], c[:TagSize-1], nil)
+	require.Equal(ErrOpen, err, "Open(short)")
+
+	require.Panics(func() { aead.Seal(nil, n[:NonceSize], nil, nil) }, "Seal(short nonce)")
+	require.Panics(func() { _, _ = aead.Open(nil, n[:NonceSize], c, nil) }, "Open(short nonce)")
+	require.Panics(func() { NewX(k[:KeySize128]) }, "NewX(short key)")
+
+	aead.Wipe()
+	require.Equal([KeySize]byte{}, aead.key, "Wipe()")
+	require.PanicsWithValue(ErrKeyReset, func() { aead.Seal(nil, n[:], nil, nil) }, "Seal() after Wipe()")
+}